@@ -1,8 +1,9 @@
 module RustCallGraphConverter
 
-go 1.14
+go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/Shopify/sarama v1.26.1 // indirect
 	github.com/bsm/sarama-cluster v2.1.15+incompatible // indirect
 	github.com/lovoo/goka v0.1.4
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/lovoo/goka"
+	"github.com/lovoo/goka/codec"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Record announcing a generated rust call graph. The call graph, the type
+// hierarchy and the optional Cargo.toml and Cargo.lock are given either as
+// paths to the files or inline.
+type callGraphRecord struct {
+	Product           string          `json:"product"`
+	Version           string          `json:"version"`
+	Toolchain         string          `json:"toolchain"`
+	CallGraphPath     string          `json:"callgraph_path"`
+	TypeHierarchyPath string          `json:"type_hierarchy_path"`
+	CargoTomlPath     string          `json:"cargo_toml_path"`
+	CargoLockPath     string          `json:"cargo_lock_path"`
+	CallGraph         json.RawMessage `json:"callgraph"`
+	TypeHierarchy     json.RawMessage `json:"type_hierarchy"`
+	CargoToml         string          `json:"cargo_toml"`
+	CargoLock         string          `json:"cargo_lock"`
+}
+
+// Runs a Kafka processor consuming records announcing generated rust call graphs,
+// converting them and emitting the results to the produce topic.
+// Offsets are committed by the processor once a record has been handled.
+func runConsumer(converter *converter) {
+	edges := []goka.Edge{
+		goka.Input(goka.Stream(*consumeKafkaTopic), new(codec.String), func(ctx goka.Context, msg interface{}) {
+			processRecord(ctx, msg.(string), converter)
+		}),
+	}
+	if *produceKafkaTopic != "[no-value-provided]" {
+		edges = append(edges, goka.Output(topic, new(codec.String)))
+	}
+
+	processor, err := goka.NewProcessor(brokers, goka.DefineGroup(goka.Group(*consumerGroup), edges...))
+	if err != nil {
+		log.Fatalf("error creating processor: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err = processor.Run(ctx); err != nil {
+			log.Fatalf("error running processor: %v", err)
+		}
+	}()
+
+	wait := make(chan os.Signal, 1)
+	signal.Notify(wait, syscall.SIGINT, syscall.SIGTERM)
+	<-wait
+	cancel()
+	<-done
+}
+
+// Converts the call graph announced by a single record. Failures are logged
+// and do not stop the processor.
+func processRecord(ctx goka.Context, msg string, converter *converter) {
+	var record callGraphRecord
+	if err := json.Unmarshal([]byte(msg), &record); err != nil {
+		reporter.Report(newFailure(ctx.Key(), stageParse, kindInvalidInput, "record: "+err.Error()))
+		return
+	}
+	if !isPathSegment(record.Product) || !isPathSegment(record.Version) {
+		reporter.Report(newFailure(ctx.Key(), stageRead, kindInvalidInput, fmt.Sprintf("invalid product %q or version %q", record.Product, record.Version)))
+		return
+	}
+	pkg := "/" + record.Product + "/" + record.Version + "/"
+	failure := func(err error, stage string, kind string) *Failure {
+		f := asFailure(err, pkg, stage, kind)
+		for _, path := range []string{record.CallGraphPath, record.TypeHierarchyPath, record.CargoTomlPath, record.CargoLockPath} {
+			if path != "" {
+				f.Inputs = append(f.Inputs, path)
+			}
+		}
+		return f
+	}
+
+	input, err := record.getFiles(pkg)
+	if err != nil {
+		reporter.Report(failure(err, stageRead, kindMissingInput))
+		return
+	}
+
+	start := time.Now()
+	fastenCallGraphs, err := converter.convert(pkg, input)
+	if err != nil {
+		reporter.Report(failure(err, stageConvert, kindConversion))
+		return
+	}
+	finalTime := time.Since(start).Seconds()
+
+	for i, fastenCallGraph := range fastenCallGraphs {
+		if *produceKafkaTopic != "[no-value-provided]" && !fastenCallGraph.IsEmpty() {
+			ctx.Emit(topic, kafkaKey(pkg, i, fastenCallGraph), string(converter.marshal(fastenCallGraph)))
+		}
+
+		if *outputDirectory != "[no-value-provided]" {
+			if _, err = writeToDisk(fastenCallGraph, converter.marshal(fastenCallGraph), pkg); err != nil {
+				reporter.Report(failure(err, stageWrite, kindIO))
+				return
+			}
+		}
+	}
+	log.Printf("Succesfully converted: %s in %f sec", pkg, finalTime)
+}
+
+// Returns the inputs of the package announced by the record.
+func (record callGraphRecord) getFiles(pkg string) (packageInput, error) {
+	input := packageInput{
+		toolchain:     record.Toolchain,
+		callGraph:     []byte(record.CallGraph),
+		typeHierarchy: []byte(record.TypeHierarchy),
+	}
+	if record.CargoToml != "" {
+		input.cargoToml = []byte(record.CargoToml)
+	}
+	if record.CargoLock != "" {
+		input.cargoLock = []byte(record.CargoLock)
+	}
+
+	var err error
+	if len(input.callGraph) == 0 {
+		if record.CallGraphPath == "" {
+			return input, newFailure(pkg, stageRead, kindMissingInput, "missing callgraph")
+		}
+		if input.callGraph, err = ioutil.ReadFile(record.CallGraphPath); err != nil {
+			return input, newFailure(pkg, stageRead, kindIO, err.Error())
+		}
+	}
+	if len(input.typeHierarchy) == 0 {
+		if record.TypeHierarchyPath == "" {
+			return input, newFailure(pkg, stageRead, kindMissingInput, "missing type hierarchy")
+		}
+		if input.typeHierarchy, err = ioutil.ReadFile(record.TypeHierarchyPath); err != nil {
+			return input, newFailure(pkg, stageRead, kindIO, err.Error())
+		}
+	}
+	if input.cargoToml == nil && record.CargoTomlPath != "" {
+		if input.cargoToml, err = ioutil.ReadFile(record.CargoTomlPath); err != nil {
+			return input, newFailure(pkg, stageRead, kindIO, err.Error())
+		}
+	}
+	if input.cargoLock == nil && record.CargoLockPath != "" {
+		if input.cargoLock, err = ioutil.ReadFile(record.CargoLockPath); err != nil {
+			return input, newFailure(pkg, stageRead, kindIO, err.Error())
+		}
+	}
+	return input, nil
+}
+
+// Checks that a product or version of a record can be used as a single element of
+// the output path, so the graph is not written outside of the output directory.
+func isPathSegment(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"RustCallGraphConverter/src/internal/cargo"
+	"RustCallGraphConverter/src/internal/cratesio"
+	"RustCallGraphConverter/src/internal/fasten"
+	"RustCallGraphConverter/src/internal/rust"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Command line arguments shared by all modes converting packages.
+type conversionSettings struct {
+	toolchain         *string
+	stdDirectory      *string
+	cratesioDump      *string
+	cratesioUserAgent *string
+	cratesioInterval  *time.Duration
+	cratesioRetries   *int
+	cratesioCache     *string
+	noTimestamps      *bool
+	allCrates         *bool
+	collapseClosures  *bool
+	expandDispatch    *bool
+	cargoDepset       *bool
+	validate          *bool
+	format            *string
+}
+
+// Output formats of converted graphs.
+const (
+	formatFlat     = "flat"
+	formatExtended = "extended"
+)
+
+// Registers the conversion arguments in the given set of flags.
+func registerConversionFlags(flags *flag.FlagSet) conversionSettings {
+	return conversionSettings{
+		toolchain:         flags.String("toolchain", rust.DefaultToolchain, "rustc toolchain of the standard library type hierarchy used for packages without a specified toolchain"),
+		stdDirectory:      flags.String("std-dir", "[no-value-provided]", "directory containing additional standard library type hierarchies named <toolchain>.json"),
+		cratesioDump:      flags.String("cratesio-dump", "[no-value-provided]", "directory of an extracted crates.io database dump to resolve timestamps from instead of the crates.io API"),
+		cratesioUserAgent: flags.String("cratesio-user-agent", "rust-call-graph-converter (https://github.com/fasten-project/rust-call-graph-converter)", "User-Agent identifying requests to the crates.io API"),
+		cratesioInterval:  flags.Duration("cratesio-interval", time.Second, "minimum interval between requests to the crates.io API"),
+		cratesioRetries:   flags.Int("cratesio-retries", 3, "number of retries of failed requests to the crates.io API"),
+		cratesioCache:     flags.String("cratesio-cache", "[no-value-provided]", "directory caching timestamps resolved with the crates.io API"),
+		noTimestamps:      flags.Bool("no-timestamps", false, "do not resolve timestamps of packages"),
+		allCrates:         flags.Bool("all-crates", false, "emit graphs of all crates found in a call graph, not only the crate of the package"),
+		collapseClosures:  flags.Bool("collapse-closures", false, "attribute closures and their calls to the function they are defined in"),
+		expandDispatch:    flags.Bool("expand-dynamic-dispatch", false, "add calls to the methods of all impls of the trait for dynamic calls"),
+		cargoDepset:       flags.Bool("cargo-depset", false, "build depsets of packages from their Cargo.toml and Cargo.lock"),
+		validate:          flags.Bool("validate", false, "validate converted graphs and report graphs with errors as failures"),
+		format:            flags.String("format", formatFlat, "output format of converted graphs, flat or extended"),
+	}
+}
+
+// Converts packages using the type hierarchies of the standard library and
+// the options configured by the command line arguments.
+type converter struct {
+	stdRegistry *rust.StdRegistry
+	toolchain   string
+	cargoDepset bool
+	validate    bool
+	format      string
+	options     rust.Options
+}
+
+// Inputs of a single package. Cargo.toml and Cargo.lock are optional.
+type packageInput struct {
+	toolchain     string
+	callGraph     []byte
+	typeHierarchy []byte
+	cargoToml     []byte
+	cargoLock     []byte
+}
+
+// Creates a converter configured by the command line arguments. Loads the type
+// hierarchy of the standard library of the default toolchain.
+func newConverter(settings conversionSettings) *converter {
+	converter := &converter{
+		stdRegistry: rust.NewStdRegistry(),
+		toolchain:   *settings.toolchain,
+		cargoDepset: *settings.cargoDepset,
+		validate:    *settings.validate,
+		format:      *settings.format,
+		options: rust.Options{
+			AllCrates:             *settings.allCrates,
+			CollapseClosures:      *settings.collapseClosures,
+			ExpandDynamicDispatch: *settings.expandDispatch,
+		},
+	}
+
+	if converter.format != formatFlat && converter.format != formatExtended {
+		log.Fatalf("unknown output format: %s", converter.format)
+	}
+
+	if *settings.stdDirectory != "[no-value-provided]" {
+		if err := converter.stdRegistry.RegisterDirectory(*settings.stdDirectory); err != nil {
+			log.Fatalf("error reading standard library type hierarchies: %v", err)
+		}
+	}
+	if _, err := converter.stdRegistry.Get(converter.toolchain); err != nil {
+		log.Fatalf("error loading standard library type hierarchy: %v (available toolchains: %s)", err,
+			strings.Join(converter.stdRegistry.Toolchains(), ", "))
+	}
+
+	if *settings.noTimestamps {
+		converter.options.Timestamps = nil
+	} else if *settings.cratesioDump != "[no-value-provided]" {
+		dump, err := cratesio.LoadDump(*settings.cratesioDump)
+		if err != nil {
+			log.Fatalf("error reading crates.io database dump: %v", err)
+		}
+		converter.options.Timestamps = dump
+	} else {
+		client := cratesio.NewClient(*settings.cratesioUserAgent)
+		client.Interval = *settings.cratesioInterval
+		client.Retries = *settings.cratesioRetries
+		if *settings.cratesioCache != "[no-value-provided]" {
+			client.CacheDirectory = *settings.cratesioCache
+		}
+		converter.options.Timestamps = client
+	}
+
+	return converter
+}
+
+// Converts the inputs of the given package to the Fasten format using standard library
+// of the input toolchain. Empty toolchain selects the default toolchain of the converter.
+func (converter *converter) convert(pkg string, input packageInput) (fastenJSONs []fasten.JSON, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newFailure(pkg, stageConvert, kindPanic, fmt.Sprint(r))
+		}
+	}()
+
+	stdTypeHierarchy, err := converter.stdTypeHierarchy(pkg, input.toolchain)
+	if err != nil {
+		return nil, newFailure(pkg, stageRead, kindMissingInput, err.Error())
+	}
+
+	var callGraph rust.JSON
+	var typeHierarchy rust.TypeHierarchy
+	if err = json.Unmarshal(input.callGraph, &callGraph); err != nil {
+		return nil, newFailure(pkg, stageParse, kindInvalidInput, "callgraph: "+err.Error())
+	}
+	if err = json.Unmarshal(input.typeHierarchy, &typeHierarchy); err != nil {
+		return nil, newFailure(pkg, stageParse, kindInvalidInput, "type hierarchy: "+err.Error())
+	}
+
+	fastenJSONs, err = callGraph.ConvertToFastenJson(typeHierarchy, stdTypeHierarchy, pkg, converter.options)
+	if err != nil {
+		return nil, newFailure(pkg, stageConvert, kindConversion, err.Error())
+	}
+
+	if converter.cargoDepset && input.cargoToml != nil && len(fastenJSONs) > 0 &&
+		fastenJSONs[0].Product == cargo.CrateName(strings.Split(pkg, "/")[1]) {
+		if err = applyCargoDepset(&fastenJSONs[0], input); err != nil {
+			return nil, newFailure(pkg, stageParse, kindInvalidInput, err.Error())
+		}
+	}
+
+	if converter.validate {
+		// The serialized graphs are validated, so the extended format is checked as written.
+		for i := range fastenJSONs {
+			written, err := fasten.Load(bytes.NewReader(converter.marshal(fastenJSONs[i])))
+			if err != nil {
+				return nil, newFailure(pkg, stageValidate, kindInvalidOutput, err.Error())
+			}
+			if findings := fasten.Validate(written); fasten.HasErrors(findings) {
+				return nil, newFailure(pkg, stageValidate, kindInvalidOutput, describeFindings(fastenJSONs[i], findings))
+			}
+		}
+	}
+	return fastenJSONs, nil
+}
+
+// Returns the type hierarchy of the standard library of the toolchain. Empty toolchain and
+// toolchains without a registered type hierarchy fall back to the default toolchain of the converter.
+func (converter *converter) stdTypeHierarchy(pkg string, toolchain string) (rust.MapTypeHierarchy, error) {
+	if toolchain == "" {
+		toolchain = converter.toolchain
+	}
+	if toolchain != converter.toolchain && !converter.stdRegistry.Has(toolchain) {
+		log.Printf("No standard library type hierarchy for toolchain %s of %s, using toolchain %s",
+			toolchain, pkg, converter.toolchain)
+		toolchain = converter.toolchain
+	}
+	return converter.stdRegistry.Get(toolchain)
+}
+
+// Describes the converter version and the options affecting the converted graphs.
+func (converter *converter) fingerprint() string {
+	return fmt.Sprintf("%s all-crates=%t format=%s cargo-depset=%t collapse-closures=%t expand-dynamic-dispatch=%t validate=%t",
+		converterVersion, converter.options.AllCrates, converter.format, converter.cargoDepset,
+		converter.options.CollapseClosures, converter.options.ExpandDynamicDispatch, converter.validate)
+}
+
+// Serializes the graph in the output format of the converter.
+func (converter *converter) marshal(fastenJSON fasten.JSON) []byte {
+	return marshalFormat(fastenJSON, converter.format)
+}
+
+// Serializes the graph in the flat or the extended format.
+func marshalFormat(fastenJSON fasten.JSON, format string) []byte {
+	if format == formatExtended {
+		extendedJSON := fastenJSON.ToExtended()
+		return extendedJSON.ToJSON()
+	}
+	return fastenJSON.ToJSON()
+}
+
+// Summarizes the errors found in the graph by its first error.
+func describeFindings(fastenJSON fasten.JSON, findings []fasten.Finding) string {
+	var errs []fasten.Finding
+	for _, finding := range findings {
+		if finding.Severity == fasten.SeverityError {
+			errs = append(errs, finding)
+		}
+	}
+	return fmt.Sprintf("%s-%s: %d errors, first %s at %s: %s",
+		fastenJSON.Product, fastenJSON.Version, len(errs), errs[0].Code, errs[0].Location, errs[0].Message)
+}
+
+// Replaces the depset of the graph with dependencies declared in Cargo.toml
+// and resolved in Cargo.lock of the package.
+func applyCargoDepset(fastenJSON *fasten.JSON, input packageInput) error {
+	manifest, err := cargo.ParseManifest(input.cargoToml)
+	if err != nil {
+		return errors.New("Cargo.toml: " + err.Error())
+	}
+	var lock *cargo.Lock
+	if input.cargoLock != nil {
+		parsedLock, err := cargo.ParseLock(input.cargoLock)
+		if err != nil {
+			return errors.New("Cargo.lock: " + err.Error())
+		}
+		lock = &parsedLock
+	}
+	cargo.ApplyDepset(fastenJSON, manifest, lock)
+	return nil
+}
+
+// Reads the toolchain from the rust-toolchain or rust-toolchain.toml file of a package.
+// Returns empty string if the package has no toolchain file.
+func readToolchain(files []string) (string, error) {
+	for _, file := range files {
+		name := filepath.Base(file)
+		if name != "rust-toolchain" && name != "rust-toolchain.toml" {
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "channel") {
+				line = strings.TrimSpace(strings.TrimPrefix(line, "channel"))
+				line = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "=")), "\"'")
+				return line, nil
+			} else if line != "" && !strings.HasPrefix(line, "[") && !strings.HasPrefix(line, "#") && !strings.Contains(line, "=") {
+				return line, nil
+			}
+		}
+	}
+	return "", nil
+}
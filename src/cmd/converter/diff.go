@@ -0,0 +1,101 @@
+package main
+
+import (
+	"RustCallGraphConverter/src/internal/fasten"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Compares two Fasten call graphs stored in files and prints their differences,
+// readable by humans or as JSON. Exits with status 1 if the graphs differ.
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the differences as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: main diff [flags] <old file> <new file>")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	graphs := make([]*fasten.JSON, 2)
+	for i, file := range flags.Args() {
+		graph, err := readFastenFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, file+": "+err.Error())
+			os.Exit(2)
+		}
+		graphs[i] = graph
+	}
+
+	difference := fasten.Diff(graphs[0], graphs[1])
+	if *asJSON {
+		_ = json.NewEncoder(os.Stdout).Encode(difference)
+	} else if !difference.IsEmpty() {
+		fmt.Printf("--- %s$%s %s\n", graphs[0].Product, graphs[0].Version, flags.Arg(0))
+		fmt.Printf("+++ %s$%s %s\n", graphs[1].Product, graphs[1].Version, flags.Arg(1))
+		printDifference(os.Stdout, difference)
+	}
+	if !difference.IsEmpty() {
+		os.Exit(1)
+	}
+}
+
+// Prints the differences grouped by kind of element, added elements prefixed
+// with + and removed elements prefixed with -.
+func printDifference(w io.Writer, difference fasten.Difference) {
+	printSection(w, "namespaces", difference.AddedNamespaces, difference.RemovedNamespaces)
+	printSection(w, "methods", difference.AddedMethods, difference.RemovedMethods)
+	printSection(w, "internal calls", formatCalls(difference.AddedInternalCalls), formatCalls(difference.RemovedInternalCalls))
+	printSection(w, "external calls", formatCalls(difference.AddedExternalCalls), formatCalls(difference.RemovedExternalCalls))
+	printSection(w, "dependencies", formatDependencies(difference.AddedDependencies), formatDependencies(difference.RemovedDependencies))
+}
+
+// Prints a section of differences if it is not empty.
+func printSection(w io.Writer, title string, added []string, removed []string) {
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s: +%d -%d\n", title, len(added), len(removed))
+	for _, element := range added {
+		fmt.Fprintln(w, "+ "+element)
+	}
+	for _, element := range removed {
+		fmt.Fprintln(w, "- "+element)
+	}
+}
+
+// Formats calls as source -> target.
+func formatCalls(calls []fasten.Call) []string {
+	formatted := make([]string, len(calls))
+	for i, call := range calls {
+		formatted[i] = call.Source + " -> " + call.Target
+	}
+	return formatted
+}
+
+// Formats dependencies as product constraints followed by kind, optional and resolved version.
+func formatDependencies(dependencies []fasten.Dependency) []string {
+	formatted := make([]string, len(dependencies))
+	for i, dependency := range dependencies {
+		elements := []string{dependency.Forge + "!" + dependency.Product, strings.Join(dependency.Constraints, ",")}
+		if dependency.Kind != "" {
+			elements = append(elements, dependency.Kind)
+		}
+		if dependency.Optional {
+			elements = append(elements, "optional")
+		}
+		if dependency.Resolved != "" {
+			elements = append(elements, "resolved "+dependency.Resolved)
+		}
+		formatted[i] = strings.Join(elements, " ")
+	}
+	return formatted
+}
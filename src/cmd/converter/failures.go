@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/lovoo/goka"
+	"github.com/lovoo/goka/codec"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stages of processing a package at which a failure can occur.
+const (
+	stageRead     = "read"
+	stageParse    = "parse"
+	stageConvert  = "convert"
+	stageValidate = "validate"
+	stageWrite    = "write"
+)
+
+// Kinds of failures.
+const (
+	kindCompilationError = "compilation-error"
+	kindMissingInput     = "missing-input"
+	kindInvalidInput     = "invalid-input"
+	kindIO               = "io"
+	kindConversion       = "conversion-error"
+	kindInvalidOutput    = "invalid-output"
+	kindPanic            = "panic"
+	kindKafka            = "kafka"
+)
+
+// Failure of processing a single package.
+type Failure struct {
+	Package   string   `json:"package"`
+	Version   string   `json:"version"`
+	Stage     string   `json:"stage"`
+	Kind      string   `json:"kind"`
+	Message   string   `json:"message"`
+	Inputs    []string `json:"inputs"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// Creates a failure of the given package in format /packageName/packageVersion/.
+func newFailure(pkg string, stage string, kind string, message string) *Failure {
+	elements := strings.Split(strings.Trim(pkg, "/"), "/")
+	failure := &Failure{
+		Package:   elements[0],
+		Stage:     stage,
+		Kind:      kind,
+		Message:   message,
+		Inputs:    []string{},
+		Timestamp: time.Now().Unix(),
+	}
+	if len(elements) > 1 {
+		failure.Version = elements[1]
+	}
+	return failure
+}
+
+// Returns the error as a failure of the given package. Errors which are not
+// failures yet are attributed to the given stage and kind.
+func asFailure(err error, pkg string, stage string, kind string) *Failure {
+	if failure, ok := err.(*Failure); ok {
+		return failure
+	}
+	return newFailure(pkg, stage, kind, err.Error())
+}
+
+func (failure *Failure) Error() string {
+	return failure.Stage + " " + failure.Kind + ": " + failure.Message
+}
+
+// Writes failures to a JSON lines file and/or a Kafka topic.
+type failureReporter struct {
+	mutex   sync.Mutex
+	file    *os.File
+	emitter *goka.Emitter
+}
+
+// Creates a failure reporter writing to the given file and Kafka topic.
+// Empty path or topic disables the respective output.
+func newFailureReporter(path string, topic string) (*failureReporter, error) {
+	reporter := &failureReporter{}
+	var err error
+	if path != "" {
+		reporter.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if topic != "" {
+		reporter.emitter, err = goka.NewEmitter(brokers, goka.Stream(topic), new(codec.String))
+		if err != nil {
+			reporter.Close()
+			return nil, err
+		}
+	}
+	return reporter, nil
+}
+
+// Logs the failure and writes it to the configured outputs.
+func (reporter *failureReporter) Report(failure *Failure) {
+	log.Printf("Failed to convert: /%s/%s/, ERROR: %s", failure.Package, failure.Version, failure)
+	if reporter == nil {
+		return
+	}
+
+	record, _ := json.Marshal(failure)
+	if reporter.file != nil {
+		reporter.mutex.Lock()
+		_, err := reporter.file.Write(append(record, '\n'))
+		reporter.mutex.Unlock()
+		if err != nil {
+			log.Printf("error writing failure: %v", err)
+		}
+	}
+	if reporter.emitter != nil {
+		if err := reporter.emitter.EmitSync(failure.Package+"-"+failure.Version, string(record)); err != nil {
+			log.Printf("error emitting failure: %v", err)
+		}
+	}
+}
+
+// Closes the outputs of the reporter.
+func (reporter *failureReporter) Close() {
+	if reporter.file != nil {
+		_ = reporter.file.Close()
+	}
+	if reporter.emitter != nil {
+		_ = reporter.emitter.Finish()
+	}
+}
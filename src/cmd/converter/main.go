@@ -1,10 +1,8 @@
 package main
 
 import (
+	"RustCallGraphConverter/src/internal/cargo"
 	"RustCallGraphConverter/src/internal/fasten"
-	"RustCallGraphConverter/src/internal/rust"
-	"encoding/json"
-	"errors"
 	"flag"
 	"github.com/lovoo/goka"
 	"github.com/lovoo/goka/codec"
@@ -22,18 +20,63 @@ var produceKafkaTopic = flag.String("t", "[no-value-provided]", "kafka topic to
 var inputDirectory = flag.String("i", ".", "directory containing rust call graphs")
 var outputDirectory = flag.String("o", "[no-value-provided]", "directory to write converted call graphs to")
 var threads = flag.Int("threads", 1, "number of threads")
+var consumeKafkaTopic = flag.String("c", "[no-value-provided]", "kafka topic announcing generated rust call graphs to consume from")
+var consumerGroup = flag.String("g", "rust-callgraph-converter", "kafka consumer group")
+var failuresFile = flag.String("f", "[no-value-provided]", "file to write failure reports to; default: failures.jsonl in the output directory")
+var errorKafkaTopic = flag.String("e", "[no-value-provided]", "kafka topic to send failure reports to")
+var manifestFile = flag.String("manifest", "[no-value-provided]", "file recording converted packages; default: manifest.jsonl in the output directory")
+var resume = flag.Bool("resume", false, "skip packages converted by an earlier run whose inputs have not changed")
+var settings = registerConversionFlags(flag.CommandLine)
 
 var brokers []string
 var topic goka.Stream
 var emitter *goka.Emitter
+var reporter *failureReporter
+var manifest *runManifest
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			runServer(os.Args[2:])
+			return
+		case "validate":
+			runValidate(os.Args[2:])
+			return
+		case "merge":
+			runMerge(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "stitch":
+			runStitch(os.Args[2:])
+			return
+		case "reach":
+			runReach(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
 	brokers = append(brokers, *broker)
 	topic = goka.Stream(*produceKafkaTopic)
 
 	var err error
+	reporter, err = createFailureReporter()
+	if err != nil {
+		log.Fatalf("error creating failure reporter: %v", err)
+	}
+	defer reporter.Close()
+
+	converter := newConverter(settings)
+
+	if *consumeKafkaTopic != "[no-value-provided]" {
+		runConsumer(converter)
+		return
+	}
+
 	if *produceKafkaTopic != "[no-value-provided]" {
 		emitter, err = goka.NewEmitter(brokers, topic, new(codec.String))
 		if err != nil {
@@ -42,13 +85,16 @@ func main() {
 		defer emitter.Finish()
 	}
 
-	callgraphs := getCallGraphs()
+	if manifest, err = createManifest(); err != nil {
+		log.Fatalf("error opening manifest: %v", err)
+	}
+	if manifest != nil {
+		defer manifest.Close()
+	} else if *resume {
+		log.Fatalf("--resume requires -o or --manifest")
+	}
 
-	// Read type hierarchy of a standard library.
-	var rawStdTypeHierarchy rust.TypeHierarchy
-	stdTypeHierarchyFile, _ := ioutil.ReadFile("src/internal/rust/standardlibrary/type_hierarchy.json")
-	_ = json.Unmarshal(stdTypeHierarchyFile, &rawStdTypeHierarchy)
-	stdTypeHierarchy := rawStdTypeHierarchy.ConvertToMap()
+	callgraphs := getCallGraphs()
 
 	guard := make(chan struct{}, *threads)
 
@@ -58,39 +104,22 @@ func main() {
 	for pkg, files := range callgraphs {
 		guard <- struct{}{}
 		go func(pkg string, files []string) {
-			var finalTime float64
-
 			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("Failed to convert: %s, ERROR: %s", pkg, r.(error))
-					<-guard
-					wg.Done()
-				} else {
-					log.Printf("Succesfully converted: %s in %f sec", pkg, finalTime)
-					<-guard
-					wg.Done()
-				}
+				<-guard
+				wg.Done()
 			}()
 
-			cgFile, typeHierarchyFile := getFiles(files)
-
-			var callGraph rust.JSON
-			var typeHierarchy rust.TypeHierarchy
-			_ = json.Unmarshal(cgFile, &callGraph)
-			_ = json.Unmarshal(typeHierarchyFile, &typeHierarchy)
-
 			start := time.Now()
-			fastenCallGraphs, _ := callGraph.ConvertToFastenJson(typeHierarchy, stdTypeHierarchy, pkg)
-			finalTime = time.Since(start).Seconds()
-
-			if *produceKafkaTopic != "[no-value-provided]" {
-				err = writeToKafka(fastenCallGraphs, pkg)
+			converted, err := processPackage(pkg, files, converter)
+			if err != nil {
+				reporter.Report(err)
+				return
 			}
-
-			if *outputDirectory != "[no-value-provided]" {
-				err = writeToDisk(fastenCallGraphs, pkg)
+			if converted {
+				log.Printf("Succesfully converted: %s in %f sec", pkg, time.Since(start).Seconds())
+			} else {
+				log.Printf("Skipped already converted: %s", pkg)
 			}
-
 		}(pkg, files)
 	}
 	wg.Wait()
@@ -98,6 +127,92 @@ func main() {
 	log.Printf("Processing of %d callgraphs took %f seconds", len(callgraphs), totalEnd)
 }
 
+// Creates the failure reporter configured by the command line arguments.
+func createFailureReporter() (*failureReporter, error) {
+	path := ""
+	if *failuresFile != "[no-value-provided]" {
+		path = *failuresFile
+	} else if *outputDirectory != "[no-value-provided]" {
+		if err := os.MkdirAll(*outputDirectory, 0755); err != nil {
+			return nil, err
+		}
+		path = filepath.Join(*outputDirectory, "failures.jsonl")
+	}
+	errorTopic := ""
+	if *errorKafkaTopic != "[no-value-provided]" {
+		errorTopic = *errorKafkaTopic
+	}
+	return newFailureReporter(path, errorTopic)
+}
+
+// Creates the manifest configured by the command line arguments.
+// Returns nil if the manifest has no location.
+func createManifest() (*runManifest, error) {
+	if *manifestFile != "[no-value-provided]" {
+		return openManifest(*manifestFile)
+	} else if *outputDirectory != "[no-value-provided]" {
+		return openManifest(filepath.Join(*outputDirectory, "manifest.jsonl"))
+	}
+	return nil, nil
+}
+
+// Reads, converts and writes a single package found in the input directory.
+// Returns false if the package is skipped, because it was already converted.
+func processPackage(pkg string, files []string, converter *converter) (bool, *Failure) {
+	failure := func(err error, stage string, kind string) *Failure {
+		f := asFailure(err, pkg, stage, kind)
+		f.Inputs = files
+		return f
+	}
+
+	input, err := getFiles(pkg, files)
+	if err != nil {
+		return false, failure(err, stageRead, kindIO)
+	}
+	if input.toolchain == "" {
+		input.toolchain = converter.toolchain
+	}
+
+	hash := hashInputs(input, converter.fingerprint())
+	if *resume && manifest.IsConverted(pkg, hash) {
+		return false, nil
+	}
+
+	fastenCallGraphs, err := converter.convert(pkg, input)
+	if err != nil {
+		return false, failure(err, stageConvert, kindConversion)
+	}
+
+	var outputs []string
+	if *produceKafkaTopic != "[no-value-provided]" {
+		for i, fastenCallGraph := range fastenCallGraphs {
+			if err = writeToKafka(fastenCallGraph, converter.marshal(fastenCallGraph), kafkaKey(pkg, i, fastenCallGraph)); err != nil {
+				return false, failure(err, stageWrite, kindKafka)
+			}
+		}
+		outputs = append(outputs, kafkaOutput(*produceKafkaTopic))
+	}
+
+	if *outputDirectory != "[no-value-provided]" {
+		for _, fastenCallGraph := range fastenCallGraphs {
+			path, err := writeToDisk(fastenCallGraph, converter.marshal(fastenCallGraph), pkg)
+			if err != nil {
+				return false, failure(err, stageWrite, kindIO)
+			}
+			if path != "" {
+				outputs = append(outputs, path)
+			}
+		}
+	}
+
+	if manifest != nil {
+		if err = manifest.Record(pkg, hash, outputs); err != nil {
+			return false, failure(err, stageWrite, kindIO)
+		}
+	}
+	return true, nil
+}
+
 // Walk the current directory and return a map containing a /packageName/packageVersion/
 // as a key and an array of containing callgraph.json and type_hierarchy.json paths.
 func getCallGraphs() map[string][]string {
@@ -107,7 +222,7 @@ func getCallGraphs() map[string][]string {
 	for _, cg := range cgs {
 		if !strings.HasPrefix(cg.Name(), ".") {
 			_ = filepath.Walk(*inputDirectory+"/"+cg.Name(), func(path string, f os.FileInfo, err error) error {
-				if f.Mode().IsRegular() && !strings.HasPrefix(f.Name(), ".") && (strings.Contains(f.Name(), ".json") || strings.Contains(f.Name(), ".log")) {
+				if f.Mode().IsRegular() && !strings.HasPrefix(f.Name(), ".") && (strings.Contains(f.Name(), ".json") || strings.Contains(f.Name(), ".log") || strings.HasPrefix(f.Name(), "rust-toolchain") || strings.HasPrefix(f.Name(), "Cargo.")) {
 					packageName := strings.TrimPrefix(path, *inputDirectory)
 					filename := strings.Split(packageName, string(filepath.Separator))
 					packageName = strings.TrimSuffix(packageName, filename[len(filename)-1])
@@ -120,61 +235,85 @@ func getCallGraphs() map[string][]string {
 	return callgraphs
 }
 
-// Given an array containing paths to callgraph.json and type_hierarchy.json return
-// content of those files in order (Callgraph, TypeHierarchy).
-func getFiles(files []string) ([]byte, []byte) {
-	var cgFile []byte
-	var typeHierarchyFile []byte
-
-	var filteredFiles []string
+// Given an array containing paths to callgraph.json, type_hierarchy.json and optionally
+// rust-toolchain, Cargo.toml and Cargo.lock return content of those files.
+func getFiles(pkg string, files []string) (packageInput, error) {
+	var input packageInput
+	var cgPath string
+	var typeHierarchyPath string
+	var optionalFiles = make(map[string]*[]byte)
 	for _, file := range files {
-		if strings.Contains(file, ".json") {
-			filteredFiles = append(filteredFiles, file)
+		if strings.HasSuffix(file, "callgraph.json") {
+			cgPath = file
+		} else if strings.HasSuffix(file, "type_hierarchy.json") {
+			typeHierarchyPath = file
+		} else if filepath.Base(file) == "Cargo.toml" {
+			optionalFiles[file] = &input.cargoToml
+		} else if filepath.Base(file) == "Cargo.lock" {
+			optionalFiles[file] = &input.cargoLock
 		}
 	}
-	if len(filteredFiles) == 0 {
-		panic(errors.New("compilation error"))
-	} else if len(filteredFiles) == 1 {
-		panic(errors.New("missing callgraph or type hierarchy"))
+	if cgPath == "" && typeHierarchyPath == "" {
+		return input, newFailure(pkg, stageRead, kindCompilationError, "no callgraph or type hierarchy generated")
+	} else if cgPath == "" {
+		return input, newFailure(pkg, stageRead, kindMissingInput, "missing callgraph")
+	} else if typeHierarchyPath == "" {
+		return input, newFailure(pkg, stageRead, kindMissingInput, "missing type hierarchy")
 	}
+	optionalFiles[cgPath] = &input.callGraph
+	optionalFiles[typeHierarchyPath] = &input.typeHierarchy
 
-	if strings.Contains(files[0], "callgraph.json") {
-		cgFile, _ = ioutil.ReadFile(files[0])
-		typeHierarchyFile, _ = ioutil.ReadFile(files[1])
-	} else if strings.Contains(files[0], "type_hierarchy.json"){
-		cgFile, _ = ioutil.ReadFile(files[1])
-		typeHierarchyFile, _ = ioutil.ReadFile(files[0])
-	} else {
-		panic(errors.New("compilation error"))
+	var err error
+	for path, content := range optionalFiles {
+		if *content, err = ioutil.ReadFile(path); err != nil {
+			return input, newFailure(pkg, stageRead, kindIO, err.Error())
+		}
+	}
+	if input.toolchain, err = readToolchain(files); err != nil {
+		return input, newFailure(pkg, stageRead, kindIO, err.Error())
 	}
 
-	return cgFile, typeHierarchyFile
+	return input, nil
 }
 
-// Writes the fastenJSON to specified Kafka topic.
-func writeToKafka(fastenCallGraph fasten.JSON, pkg string) error {
+// Writes the serialized fastenJSON to specified Kafka topic with the given key.
+func writeToKafka(fastenCallGraph fasten.JSON, fastenJson []byte, key string) error {
 	var err error
 	if !fastenCallGraph.IsEmpty() {
-		fastenJson, _ := json.Marshal(fastenCallGraph)
-		err = runEmitter(fastenJson, pkg)
+		err = runEmitter(fastenJson, key)
 	}
 	return err
 }
 
-// Writes the fastenJSON to "specified_output_directory"/fasten/pkg.
-func writeToDisk(fastenCallGraph fasten.JSON, pkg string) error {
-	var err error
-	if !fastenCallGraph.IsEmpty() {
-		path := *outputDirectory + "/fasten" + pkg
-		err = os.MkdirAll(path, 0755)
-		fastenJson, _ := json.Marshal(fastenCallGraph)
-		f, err := os.Create(path + fastenCallGraph.Product + "-" + fastenCallGraph.Version + ".json")
-		if err == nil {
-			_, err = f.Write(fastenJson)
-			err = f.Close()
+// Key of the Kafka record of the i-th graph converted from a package. The graph of the
+// package, which comes first, is keyed by the package. Graphs of the other crates emitted
+// with --all-crates are keyed by their own crate in format /product/version/.
+func kafkaKey(pkg string, i int, fastenCallGraph fasten.JSON) string {
+	if i == 0 && fastenCallGraph.Product == cargo.CrateName(strings.Split(pkg, "/")[1]) {
+		return pkg
+	}
+	return "/" + fastenCallGraph.Product + "/" + fastenCallGraph.Version + "/"
+}
+
+// Writes the serialized fastenJSON to "specified_output_directory"/fasten/pkg.
+// Returns the path of the written file or empty string if nothing was written.
+func writeToDisk(fastenCallGraph fasten.JSON, fastenJson []byte, pkg string) (string, error) {
+	if fastenCallGraph.IsEmpty() {
+		return "", nil
+	}
+	path := *outputDirectory + "/fasten" + pkg
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", err
+	}
+	path += fastenCallGraph.Product + "-" + fastenCallGraph.Version + ".json"
+	f, err := os.Create(path)
+	if err == nil {
+		_, err = f.Write(fastenJson)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
 		}
 	}
-	return err
+	return path, err
 }
 
 // Sends message to Kafka topic.
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry of the run manifest recording a successfully converted package.
+type manifestEntry struct {
+	Package   string   `json:"package"`
+	Hash      string   `json:"hash"`
+	Outputs   []string `json:"outputs"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// Manifest of a batch run stored as a JSON lines file. Entries are appended
+// as soon as a package is converted, so the manifest survives crashes.
+// Later entries of a package override the earlier ones.
+type runManifest struct {
+	mutex   sync.Mutex
+	entries map[string]manifestEntry
+	file    *os.File
+}
+
+// Opens the manifest at the given path, reading entries of earlier runs.
+func openManifest(path string) (*runManifest, error) {
+	manifest := &runManifest{entries: make(map[string]manifestEntry)}
+
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var entry manifestEntry
+			// A partially written last line of a crashed run is skipped.
+			if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+				manifest.entries[entry.Package] = entry
+			}
+		}
+		err = scanner.Err()
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var err error
+	manifest.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Checks if the package was converted from inputs with the given hash and
+// all of its outputs written to disk are still present.
+func (manifest *runManifest) IsConverted(pkg string, hash string) bool {
+	manifest.mutex.Lock()
+	entry, ok := manifest.entries[pkg]
+	manifest.mutex.Unlock()
+	if !ok || entry.Hash != hash {
+		return false
+	}
+	for _, output := range entry.Outputs {
+		if !isKafkaOutput(output) {
+			if _, err := os.Stat(output); err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Records a successfully converted package.
+func (manifest *runManifest) Record(pkg string, hash string, outputs []string) error {
+	entry := manifestEntry{
+		Package:   pkg,
+		Hash:      hash,
+		Outputs:   outputs,
+		Timestamp: time.Now().Unix(),
+	}
+	if entry.Outputs == nil {
+		entry.Outputs = []string{}
+	}
+	line, _ := json.Marshal(entry)
+
+	manifest.mutex.Lock()
+	defer manifest.mutex.Unlock()
+	manifest.entries[pkg] = entry
+	_, err := manifest.file.Write(append(line, '\n'))
+	return err
+}
+
+// Closes the manifest file.
+func (manifest *runManifest) Close() {
+	_ = manifest.file.Close()
+}
+
+// Version of the converter. It is part of the hashes recorded in the run manifest,
+// so it must be increased whenever the conversion output changes.
+const converterVersion = "0.2.0"
+
+// Computes the hash of the converter fingerprint, the toolchain and the content
+// of the inputs of a package.
+func hashInputs(input packageInput, fingerprint string) string {
+	hash := sha256.New()
+	for _, content := range [][]byte{[]byte(fingerprint), []byte(input.toolchain), input.callGraph, input.typeHierarchy, input.cargoToml, input.cargoLock} {
+		hash.Write(content)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Output location of a package sent to the Kafka topic.
+func kafkaOutput(topic string) string {
+	return "kafka:" + topic
+}
+
+// Checks if the output location is a Kafka topic.
+func isKafkaOutput(output string) bool {
+	return strings.HasPrefix(output, "kafka:")
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"RustCallGraphConverter/src/internal/fasten"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Merges Fasten call graphs of the same product and version stored in the given
+// files and writes the merged graph to the output file or to stdout.
+func runMerge(args []string) {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := flags.String("o", "", "file to write the merged graph to; default: stdout")
+	format := flags.String("format", formatFlat, "output format of the merged graph, flat or extended")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: main merge [flags] <file>...")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 || (*format != formatFlat && *format != formatExtended) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	graphs := make([]*fasten.JSON, 0, flags.NArg())
+	for _, file := range flags.Args() {
+		graph, err := readFastenFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, file+": "+err.Error())
+			os.Exit(1)
+		}
+		graphs = append(graphs, graph)
+	}
+	merged, err := fasten.Merge(graphs...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	content := marshalFormat(*merged, *format)
+	if *output == "" {
+		_, err = os.Stdout.Write(content)
+	} else {
+		err = ioutil.WriteFile(*output, content, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"RustCallGraphConverter/src/internal/stitch"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Values of a flag given multiple times.
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, " ")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// Checks whether a package reaches the given method URIs, within its own graph and
+// through the graphs of its dependencies found in the store. Prints a witness call
+// chain for every reachable target and exits with status 1 if any target is reachable.
+func runReach(args []string) {
+	flags := flag.NewFlagSet("reach", flag.ExitOnError)
+	storeDirectory := flags.String("store", "", "directory containing converted graphs of the dependencies; default: only the package itself")
+	asJSON := flags.Bool("json", false, "print the witnesses as JSON lines")
+	var entries stringList
+	flags.Var(&entries, "entry", "URI of an entry point, can be given multiple times; default: all methods of the package")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: main reach [flags] <file> <target uri>...")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	root, err := readFastenFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, flags.Arg(0)+": "+err.Error())
+		os.Exit(2)
+	}
+	store := stitch.NewStore()
+	if *storeDirectory != "" {
+		if store, err = stitch.OpenStore(*storeDirectory); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	witnesses, err := stitch.Reach(stitch.Stitch(root, store), entries, flags.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	reachable := false
+	encoder := json.NewEncoder(os.Stdout)
+	for _, witness := range witnesses {
+		reachable = reachable || witness.Reachable
+		if *asJSON {
+			_ = encoder.Encode(witness)
+		} else if witness.Reachable {
+			fmt.Println("reachable: " + witness.Target)
+			fmt.Println("    " + strings.Join(witness.Chain, "\n -> "))
+		} else {
+			fmt.Println("unreachable: " + witness.Target)
+		}
+	}
+	if reachable {
+		os.Exit(1)
+	}
+}
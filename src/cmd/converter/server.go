@@ -0,0 +1,172 @@
+package main
+
+import (
+	"RustCallGraphConverter/src/internal/fasten"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// Maximum size of a conversion request body.
+const maxRequestSize = 1 << 30
+
+// Request to convert a single package. The call graph, the type hierarchy
+// and the optional Cargo.toml and Cargo.lock are passed inline.
+type conversionRequest struct {
+	Product       string          `json:"product"`
+	Version       string          `json:"version"`
+	Toolchain     string          `json:"toolchain"`
+	CallGraph     json.RawMessage `json:"callgraph"`
+	TypeHierarchy json.RawMessage `json:"type_hierarchy"`
+	CargoToml     string          `json:"cargo_toml"`
+	CargoLock     string          `json:"cargo_lock"`
+}
+
+// Returns the inputs of the requested package.
+func (request conversionRequest) input() packageInput {
+	input := packageInput{
+		toolchain:     request.Toolchain,
+		callGraph:     request.CallGraph,
+		typeHierarchy: request.TypeHierarchy,
+	}
+	if request.CargoToml != "" {
+		input.cargoToml = []byte(request.CargoToml)
+	}
+	if request.CargoLock != "" {
+		input.cargoLock = []byte(request.CargoLock)
+	}
+	return input
+}
+
+// Runs an HTTP server converting call graphs on demand.
+func runServer(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	address := flags.String("addr", ":8080", "address to listen on in format host:port")
+	settings := registerConversionFlags(flags)
+	_ = flags.Parse(args)
+
+	converter := newConverter(settings)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", func(w http.ResponseWriter, r *http.Request) {
+		handleConvert(w, r, converter)
+	})
+
+	log.Printf("Listening on %s", *address)
+	log.Fatal(http.ListenAndServe(*address, mux))
+}
+
+// Handles POST /convert. Accepts either a JSON conversionRequest or a multipart form
+// with product and version fields, callgraph.json and type_hierarchy.json files and
+// optional Cargo.toml and Cargo.lock files.
+// Responds with the Fasten call graph of the package, or with an array of graphs
+// of all crates when the converter emits all crates.
+func handleConvert(w http.ResponseWriter, r *http.Request, converter *converter) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+	request, err := readConversionRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	pkg := "/" + request.Product + "/" + request.Version + "/"
+	start := time.Now()
+	fastenCallGraphs, err := converter.convert(pkg, request.input())
+	if err != nil {
+		failure := asFailure(err, pkg, stageConvert, kindConversion)
+		reporter.Report(failure)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(failure)
+		return
+	}
+	log.Printf("Succesfully converted: %s in %f sec", pkg, time.Since(start).Seconds())
+
+	w.Header().Set("Content-Type", "application/json")
+	if converter.options.AllCrates {
+		response := make([]json.RawMessage, len(fastenCallGraphs))
+		for i, fastenCallGraph := range fastenCallGraphs {
+			response[i] = converter.marshal(fastenCallGraph)
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	} else if len(fastenCallGraphs) > 0 {
+		_, _ = w.Write(converter.marshal(fastenCallGraphs[0]))
+	} else {
+		_, _ = w.Write(converter.marshal(fasten.JSON{}))
+	}
+}
+
+// Reads a conversion request from a JSON or a multipart body.
+func readConversionRequest(r *http.Request) (conversionRequest, error) {
+	var request conversionRequest
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return request, err
+		}
+		request.Product = r.FormValue("product")
+		request.Version = r.FormValue("version")
+		request.Toolchain = r.FormValue("toolchain")
+		for field, content := range map[string]*json.RawMessage{
+			"callgraph.json":      &request.CallGraph,
+			"type_hierarchy.json": &request.TypeHierarchy,
+		} {
+			file, _, err := r.FormFile(field)
+			if err != nil {
+				return request, errors.New("missing " + field)
+			}
+			*content, err = ioutil.ReadAll(file)
+			_ = file.Close()
+			if err != nil {
+				return request, err
+			}
+		}
+		for field, content := range map[string]*string{
+			"Cargo.toml": &request.CargoToml,
+			"Cargo.lock": &request.CargoLock,
+		} {
+			file, _, err := r.FormFile(field)
+			if err != nil {
+				continue
+			}
+			bytes, err := ioutil.ReadAll(file)
+			_ = file.Close()
+			if err != nil {
+				return request, err
+			}
+			*content = string(bytes)
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return request, err
+	}
+
+	if request.Product == "" || request.Version == "" {
+		return request, errors.New("missing product or version")
+	}
+	if len(request.CallGraph) == 0 {
+		return request, errors.New("missing callgraph")
+	}
+	if len(request.TypeHierarchy) == 0 {
+		return request, errors.New("missing type hierarchy")
+	}
+	return request, nil
+}
+
+// Writes an error as a JSON object with the given status code.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
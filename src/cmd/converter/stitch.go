@@ -0,0 +1,54 @@
+package main
+
+import (
+	"RustCallGraphConverter/src/internal/stitch"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Stitches the Fasten call graph of a package with the graphs of its dependencies
+// found in a store directory and writes the global call graph to the output file
+// or to stdout. Prints a summary of resolved and unresolved calls to stderr.
+func runStitch(args []string) {
+	flags := flag.NewFlagSet("stitch", flag.ExitOnError)
+	storeDirectory := flags.String("store", "", "directory containing converted graphs of the dependencies, e.g. the output directory of the converter")
+	output := flags.String("o", "", "file to write the stitched graph to; default: stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: main stitch -store <directory> [flags] <file>")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 || *storeDirectory == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	root, err := readFastenFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, flags.Arg(0)+": "+err.Error())
+		os.Exit(1)
+	}
+	store, err := stitch.OpenStore(*storeDirectory)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	stitched := stitch.Stitch(root, store)
+	content, _ := json.Marshal(stitched)
+	if *output == "" {
+		_, err = os.Stdout.Write(content)
+	} else {
+		err = ioutil.WriteFile(*output, content, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Stitched %d packages: %d internal, %d resolved and %d unresolved calls, %d missing dependencies\n",
+		len(stitched.Packages), len(stitched.InternalCalls), len(stitched.ResolvedCalls),
+		len(stitched.UnresolvedCalls), len(stitched.MissingDependencies))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"RustCallGraphConverter/src/internal/fasten"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Findings of a single Fasten call graph file.
+type validationResult struct {
+	File     string           `json:"file"`
+	Valid    bool             `json:"valid"`
+	Findings []fasten.Finding `json:"findings"`
+}
+
+// Validates Fasten call graphs stored in the given files and directories. Prints the
+// findings of every file as a JSON line and exits with status 1 if any file is invalid.
+func runValidate(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	onlyInvalid := flags.Bool("only-invalid", false, "print only files containing errors")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: main validate [flags] <file or directory>...")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	files, err := getFastenFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	valid := true
+	encoder := json.NewEncoder(os.Stdout)
+	for _, file := range files {
+		result := validateFile(file)
+		valid = valid && result.Valid
+		if !*onlyInvalid || !result.Valid {
+			_ = encoder.Encode(result)
+		}
+	}
+	if !valid {
+		os.Exit(1)
+	}
+}
+
+// Validates a single Fasten call graph file.
+func validateFile(path string) validationResult {
+	result := validationResult{File: path}
+	fastenJSON, err := readFastenFile(path)
+	if err != nil {
+		result.Findings = []fasten.Finding{{
+			Severity: fasten.SeverityError,
+			Code:     fasten.CodeInvalidJSON,
+			Message:  err.Error(),
+		}}
+	} else {
+		result.Findings = fasten.Validate(fastenJSON)
+	}
+	result.Valid = !fasten.HasErrors(result.Findings)
+	return result
+}
+
+// Reads a Fasten call graph in the flat or the extended format from the file.
+func readFastenFile(path string) (*fasten.JSON, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return fasten.Load(file)
+}
+
+// Returns the given files and all .json files found in the given directories.
+func getFastenFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(file, ".json") {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
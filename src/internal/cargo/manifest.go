@@ -0,0 +1,203 @@
+package cargo
+
+import (
+	"RustCallGraphConverter/src/internal/fasten"
+	"github.com/BurntSushi/toml"
+	"sort"
+	"strings"
+)
+
+// Kinds of dependencies declared in Cargo.toml.
+const (
+	NormalDependency = "normal"
+	DevDependency    = "dev"
+	BuildDependency  = "build"
+)
+
+// Dependency declared in Cargo.toml.
+type Requirement struct {
+	// Name of the package on crates.io.
+	Name        string
+	Requirement string
+	Kind        string
+	Optional    bool
+}
+
+// Manifest of a package read from Cargo.toml.
+type Manifest struct {
+	Dependencies []Requirement
+}
+
+// Lock file of a package read from Cargo.lock.
+type Lock struct {
+	// Versions of each package resolved in the lock file.
+	Packages map[string][]Version
+}
+
+// Sections of Cargo.toml declaring dependencies of each kind.
+var dependencySections = map[string]string{
+	"dependencies":       NormalDependency,
+	"dev-dependencies":   DevDependency,
+	"dev_dependencies":   DevDependency,
+	"build-dependencies": BuildDependency,
+	"build_dependencies": BuildDependency,
+}
+
+// Parses Cargo.toml. Dependencies of every target are included. Dependencies without
+// a version requirement (path and git dependencies) are not published on crates.io
+// and are skipped.
+func ParseManifest(content []byte) (Manifest, error) {
+	var manifest Manifest
+	var document map[string]interface{}
+	if _, err := toml.Decode(string(content), &document); err != nil {
+		return manifest, err
+	}
+
+	manifest.Dependencies = append(manifest.Dependencies, parseDependencySections(document)...)
+	if targets, ok := document["target"].(map[string]interface{}); ok {
+		for _, target := range targets {
+			if sections, ok := target.(map[string]interface{}); ok {
+				manifest.Dependencies = append(manifest.Dependencies, parseDependencySections(sections)...)
+			}
+		}
+	}
+
+	// Sort normal, dev and build dependencies by name and drop dependencies
+	// declared in several sections.
+	sort.SliceStable(manifest.Dependencies, func(i, j int) bool {
+		if manifest.Dependencies[i].Kind != manifest.Dependencies[j].Kind {
+			return manifest.Dependencies[i].Kind > manifest.Dependencies[j].Kind
+		}
+		return manifest.Dependencies[i].Name < manifest.Dependencies[j].Name
+	})
+	var dependencies []Requirement
+	for i, requirement := range manifest.Dependencies {
+		if i == 0 || requirement != manifest.Dependencies[i-1] {
+			dependencies = append(dependencies, requirement)
+		}
+	}
+	manifest.Dependencies = dependencies
+	return manifest, nil
+}
+
+// Parses the dependency sections of a table of Cargo.toml.
+func parseDependencySections(sections map[string]interface{}) []Requirement {
+	var requirements []Requirement
+	for section, kind := range dependencySections {
+		dependencies, ok := sections[section].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name, value := range dependencies {
+			requirement := Requirement{Name: name, Kind: kind}
+			switch declaration := value.(type) {
+			case string:
+				requirement.Requirement = declaration
+			case map[string]interface{}:
+				requirement.Requirement, _ = declaration["version"].(string)
+				if pkg, ok := declaration["package"].(string); ok {
+					requirement.Name = pkg
+				}
+				requirement.Optional, _ = declaration["optional"].(bool)
+			}
+			if requirement.Requirement != "" {
+				requirements = append(requirements, requirement)
+			}
+		}
+	}
+	return requirements
+}
+
+// Parses Cargo.lock.
+func ParseLock(content []byte) (Lock, error) {
+	lock := Lock{Packages: make(map[string][]Version)}
+	var document struct {
+		Package []struct {
+			Name    string `toml:"name"`
+			Version string `toml:"version"`
+		} `toml:"package"`
+	}
+	if _, err := toml.Decode(string(content), &document); err != nil {
+		return lock, err
+	}
+	for _, pkg := range document.Package {
+		if version, err := ParseVersion(pkg.Version); err == nil {
+			lock.Packages[pkg.Name] = append(lock.Packages[pkg.Name], version)
+		}
+	}
+	return lock, nil
+}
+
+// Returns the highest version of the package resolved in the lock file
+// matching the range.
+func (lock Lock) Resolve(name string, versionRange Range) (Version, bool) {
+	var resolved Version
+	found := false
+	for _, version := range lock.Packages[name] {
+		if versionRange.Contains(version) && (!found || version.Compare(resolved) > 0) {
+			resolved = version
+			found = true
+		}
+	}
+	return resolved, found
+}
+
+// Replaces the depset of the graph with the dependencies declared in the manifest.
+// Products are named like the crates in call graphs and dependencies are resolved
+// with the lock file when it is given. Dependencies found in calls are kept only if
+// no declared dependency of their product matches their version.
+func ApplyDepset(fastenJSON *fasten.JSON, manifest Manifest, lock *Lock) {
+	var depset = []fasten.Dependency{}
+	var ranges = make(map[string][]Range)
+	for _, requirement := range manifest.Dependencies {
+		versionRange, err := ParseRequirement(requirement.Requirement)
+		if err != nil {
+			continue
+		}
+		dependency := fasten.Dependency{
+			Product:     CrateName(requirement.Name),
+			Forge:       "cratesio",
+			Constraints: []string{versionRange.String()},
+			Kind:        requirement.Kind,
+			Optional:    requirement.Optional,
+		}
+		if lock != nil {
+			if version, ok := lock.Resolve(requirement.Name, versionRange); ok {
+				dependency.Resolved = version.String()
+			}
+		}
+		depset = append(depset, dependency)
+		ranges[dependency.Product] = append(ranges[dependency.Product], versionRange)
+	}
+
+	for _, inner := range fastenJSON.Depset {
+		for _, dependency := range inner {
+			if !matchesAny(dependency, ranges[dependency.Product]) {
+				depset = append(depset, dependency)
+			}
+		}
+	}
+	fastenJSON.Depset = [][]fasten.Dependency{depset}
+}
+
+// Checks if the exact version constraint of a dependency found in calls
+// is in one of the ranges.
+func matchesAny(dependency fasten.Dependency, ranges []Range) bool {
+	for _, constraint := range dependency.Constraints {
+		version, err := ParseVersion(strings.Trim(constraint, "[]"))
+		if err != nil {
+			continue
+		}
+		for _, versionRange := range ranges {
+			if versionRange.Contains(version) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the name of the library crate of a package.
+func CrateName(pkg string) string {
+	return strings.ReplaceAll(pkg, "-", "_")
+}
@@ -0,0 +1,316 @@
+package cargo
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Semantic version. Pre-release and build metadata are kept as written.
+type Version struct {
+	Major      int64
+	Minor      int64
+	Patch      int64
+	PreRelease string
+}
+
+// Parses a semantic version.
+func ParseVersion(value string) (Version, error) {
+	var version Version
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, "+"); i >= 0 {
+		value = value[:i]
+	}
+	if i := strings.Index(value, "-"); i >= 0 {
+		version.PreRelease = value[i+1:]
+		value = value[:i]
+	}
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return version, errors.New("invalid version " + value)
+	}
+	numbers := []*int64{&version.Major, &version.Minor, &version.Patch}
+	for i, part := range parts {
+		number, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || number < 0 {
+			return version, errors.New("invalid version " + value)
+		}
+		*numbers[i] = number
+	}
+	return version, nil
+}
+
+// Compares two versions. Returns -1, 0 or 1 if the version is lower, equal
+// or greater than the other version.
+func (version Version) Compare(other Version) int {
+	for _, pair := range [][2]int64{
+		{version.Major, other.Major},
+		{version.Minor, other.Minor},
+		{version.Patch, other.Patch},
+	} {
+		if pair[0] < pair[1] {
+			return -1
+		} else if pair[0] > pair[1] {
+			return 1
+		}
+	}
+	switch {
+	case version.PreRelease == other.PreRelease:
+		return 0
+	case version.PreRelease == "":
+		return 1
+	case other.PreRelease == "":
+		return -1
+	case version.PreRelease < other.PreRelease:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func (version Version) String() string {
+	value := strconv.FormatInt(version.Major, 10) + "." +
+		strconv.FormatInt(version.Minor, 10) + "." +
+		strconv.FormatInt(version.Patch, 10)
+	if version.PreRelease != "" {
+		value += "-" + version.PreRelease
+	}
+	return value
+}
+
+// Bound of a version range. Nil version means unbounded.
+type bound struct {
+	version   *Version
+	inclusive bool
+}
+
+// Range of versions matched by a Cargo version requirement.
+type Range struct {
+	lower bound
+	upper bound
+}
+
+// Parses a Cargo version requirement (https://doc.rust-lang.org/cargo/reference/specifying-dependencies.html)
+// such as "1.2", "^1.2.3", "~1.2", "=1.2.3", "1.*" or ">= 1.2, < 1.5".
+func ParseRequirement(requirement string) (Range, error) {
+	result := Range{}
+	if strings.TrimSpace(requirement) == "" {
+		return result, errors.New("empty requirement")
+	}
+	for _, comparator := range strings.Split(requirement, ",") {
+		comparatorRange, err := parseComparator(strings.TrimSpace(comparator))
+		if err != nil {
+			return result, errors.New("invalid requirement " + requirement + ": " + err.Error())
+		}
+		result = result.intersect(comparatorRange)
+	}
+	return result, nil
+}
+
+// Parses a constraint in the interval notation of Fasten, the format of Range.String.
+func ParseConstraint(constraint string) (Range, error) {
+	result := Range{}
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "*" {
+		return result, nil
+	}
+	if len(constraint) < 3 || !strings.ContainsAny(constraint[:1], "[(") || !strings.ContainsAny(constraint[len(constraint)-1:], "])") {
+		return result, errors.New("invalid constraint " + constraint)
+	}
+	bounds := strings.Split(constraint[1:len(constraint)-1], ",")
+	if len(bounds) == 1 {
+		bounds = append(bounds, bounds[0])
+	}
+	if len(bounds) != 2 {
+		return result, errors.New("invalid constraint " + constraint)
+	}
+	for i, value := range bounds {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		version, err := ParseVersion(value)
+		if err != nil {
+			return result, errors.New("invalid constraint " + constraint + ": " + err.Error())
+		}
+		if i == 0 {
+			result.lower = bound{version: &version, inclusive: constraint[0] == '['}
+		} else {
+			result.upper = bound{version: &version, inclusive: constraint[len(constraint)-1] == ']'}
+		}
+	}
+	return result, nil
+}
+
+// Parses a single comparator of a requirement.
+func parseComparator(comparator string) (Range, error) {
+	operator := ""
+	for _, candidate := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(comparator, candidate) {
+			operator = candidate
+			comparator = strings.TrimSpace(comparator[len(candidate):])
+			break
+		}
+	}
+
+	version, precision, err := parsePartialVersion(comparator)
+	if err != nil {
+		return Range{}, err
+	}
+	if precision == 0 {
+		if operator != "" && operator != "=" && operator != "^" && operator != "~" {
+			return Range{}, errors.New("wildcard with operator " + operator)
+		}
+		return Range{}, nil
+	}
+	if operator == "" {
+		if strings.Contains(comparator, "*") || strings.ContainsAny(comparator, "xX") {
+			operator = "="
+		} else {
+			operator = "^"
+		}
+	}
+
+	// Upper bound of the version with only the given number of its parts significant.
+	next := func(parts int) *Version {
+		upper := Version{Major: version.Major, Minor: version.Minor, Patch: version.Patch}
+		switch parts {
+		case 1:
+			upper = Version{Major: version.Major + 1}
+		case 2:
+			upper = Version{Major: version.Major, Minor: version.Minor + 1}
+		default:
+			upper.Patch++
+		}
+		return &upper
+	}
+
+	switch operator {
+	case "=":
+		if precision == 3 {
+			return Range{lower: bound{&version, true}, upper: bound{&version, true}}, nil
+		}
+		return Range{lower: bound{&version, true}, upper: bound{next(precision), false}}, nil
+	case "^":
+		switch {
+		case version.Major > 0 || precision == 1:
+			return Range{lower: bound{&version, true}, upper: bound{next(1), false}}, nil
+		case version.Minor > 0 || precision == 2:
+			return Range{lower: bound{&version, true}, upper: bound{next(2), false}}, nil
+		default:
+			return Range{lower: bound{&version, true}, upper: bound{next(3), false}}, nil
+		}
+	case "~":
+		if precision == 1 {
+			return Range{lower: bound{&version, true}, upper: bound{next(1), false}}, nil
+		}
+		return Range{lower: bound{&version, true}, upper: bound{next(2), false}}, nil
+	case ">=":
+		return Range{lower: bound{&version, true}}, nil
+	case ">":
+		if precision < 3 {
+			return Range{lower: bound{next(precision), true}}, nil
+		}
+		return Range{lower: bound{&version, false}}, nil
+	case "<=":
+		if precision < 3 {
+			return Range{upper: bound{next(precision), false}}, nil
+		}
+		return Range{upper: bound{&version, true}}, nil
+	default:
+		return Range{upper: bound{&version, false}}, nil
+	}
+}
+
+// Parses a version with optional minor and patch parts, which may be wildcards.
+// Returns the version with missing parts set to zero and the number of present parts.
+func parsePartialVersion(value string) (Version, int, error) {
+	var version Version
+	if i := strings.Index(value, "+"); i >= 0 {
+		value = value[:i]
+	}
+	if i := strings.Index(value, "-"); i >= 0 {
+		version.PreRelease = value[i+1:]
+		value = value[:i]
+	}
+	parts := strings.Split(value, ".")
+	if len(parts) > 3 {
+		return version, 0, errors.New("invalid version " + value)
+	}
+	numbers := []*int64{&version.Major, &version.Minor, &version.Patch}
+	for i, part := range parts {
+		if part == "*" || part == "x" || part == "X" {
+			return version, i, nil
+		}
+		number, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || number < 0 {
+			return version, 0, errors.New("invalid version " + value)
+		}
+		*numbers[i] = number
+	}
+	return version, len(parts), nil
+}
+
+// Returns the intersection of two ranges.
+func (r Range) intersect(other Range) Range {
+	result := r
+	if other.lower.version != nil {
+		if result.lower.version == nil {
+			result.lower = other.lower
+		} else if c := other.lower.version.Compare(*result.lower.version); c > 0 || (c == 0 && !other.lower.inclusive) {
+			result.lower = other.lower
+		}
+	}
+	if other.upper.version != nil {
+		if result.upper.version == nil {
+			result.upper = other.upper
+		} else if c := other.upper.version.Compare(*result.upper.version); c < 0 || (c == 0 && !other.upper.inclusive) {
+			result.upper = other.upper
+		}
+	}
+	return result
+}
+
+// Checks if the version is in the range.
+func (r Range) Contains(version Version) bool {
+	if r.lower.version != nil {
+		c := version.Compare(*r.lower.version)
+		if c < 0 || (c == 0 && !r.lower.inclusive) {
+			return false
+		}
+	}
+	if r.upper.version != nil {
+		c := version.Compare(*r.upper.version)
+		if c > 0 || (c == 0 && !r.upper.inclusive) {
+			return false
+		}
+	}
+	return true
+}
+
+// Formats the range in the interval notation of Fasten constraints,
+// e.g. "[1.2.3]", "[1.2.0,2.0.0)" or "[1.0.0,)". Unbounded range is formatted as "*".
+func (r Range) String() string {
+	if r.lower.version == nil && r.upper.version == nil {
+		return "*"
+	}
+	if r.lower.version != nil && r.upper.version != nil &&
+		r.lower.inclusive && r.upper.inclusive && r.lower.version.Compare(*r.upper.version) == 0 {
+		return "[" + r.lower.version.String() + "]"
+	}
+
+	value := "("
+	if r.lower.version != nil {
+		if r.lower.inclusive {
+			value = "["
+		}
+		value += r.lower.version.String()
+	}
+	value += ","
+	if r.upper.version != nil {
+		value += r.upper.version.String()
+		if r.upper.inclusive {
+			return value + "]"
+		}
+	}
+	return value + ")"
+}
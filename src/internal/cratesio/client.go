@@ -0,0 +1,212 @@
+package cratesio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Base URL of the crates.io API.
+const DefaultBaseURL = "https://crates.io/api/v1"
+
+// Content of a cache entry of a release missing on crates.io.
+const notFound = "not-found"
+
+// Error returned for releases missing on crates.io.
+var ErrNotFound = errors.New("release not found on crates.io")
+
+// Response of the crates.io API for a single version of a crate.
+type versionResponse struct {
+	Version struct {
+		CreatedAt string `json:"created_at"`
+	} `json:"version"`
+}
+
+// Resolves timestamps of releases with the crates.io API following its crawler
+// policy (https://crates.io/policies#crawlers). Requests are identified by the
+// User-Agent, throttled to one per Interval and retried with exponential backoff.
+// Resolved timestamps are cached on disk when CacheDirectory is set.
+type Client struct {
+	BaseURL        string
+	UserAgent      string
+	HTTPClient     *http.Client
+	Interval       time.Duration
+	Retries        int
+	Backoff        time.Duration
+	CacheDirectory string
+
+	mutex       sync.Mutex
+	lastRequest time.Time
+}
+
+// Creates a client of the public crates.io API with one request per second,
+// three retries starting with one second backoff and no cache.
+func NewClient(userAgent string) *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		UserAgent:  userAgent,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Interval:   time.Second,
+		Retries:    3,
+		Backoff:    time.Second,
+	}
+}
+
+// Returns the unix timestamp of the release of the given version of a crate.
+// Returns ErrNotFound if crates.io does not know the release.
+func (client *Client) Timestamp(product string, version string) (int64, error) {
+	if !isPathSegment(product) || !isPathSegment(version) {
+		return -1, fmt.Errorf("invalid release %q %q", product, version)
+	}
+	if timestamp, cached, err := client.readCache(product, version); cached {
+		return timestamp, err
+	}
+
+	timestamp, err := client.fetch(product, version)
+	if err == nil || err == ErrNotFound {
+		client.writeCache(product, version, timestamp, err)
+	}
+	return timestamp, err
+}
+
+// Queries crates.io, retrying requests failed by the network, throttling or
+// server errors.
+func (client *Client) fetch(product string, version string) (int64, error) {
+	backoff := client.Backoff
+	var err error
+	for attempt := 0; attempt <= client.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var timestamp int64
+		var retryAfter time.Duration
+		var retry bool
+		timestamp, retryAfter, retry, err = client.request(product, version)
+		if !retry {
+			return timestamp, err
+		}
+		if retryAfter > backoff {
+			backoff = retryAfter
+		}
+	}
+	return -1, err
+}
+
+// Sends a single request. Returns whether the request may be retried and
+// a delay requested by the server for throttled requests.
+func (client *Client) request(product string, version string) (int64, time.Duration, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, client.BaseURL+"/crates/"+url.PathEscape(product)+"/"+url.PathEscape(version), nil)
+	if err != nil {
+		return -1, 0, false, err
+	}
+	req.Header.Set("User-Agent", client.UserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	client.wait()
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return -1, 0, true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return -1, 0, false, ErrNotFound
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return -1, time.Duration(seconds) * time.Second, true, errors.New("crates.io responded with " + resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return -1, 0, false, errors.New("crates.io responded with " + resp.Status)
+	}
+
+	var response versionResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return -1, 0, false, err
+	}
+	timestamp, err := parseTimestamp(response.Version.CreatedAt)
+	return timestamp, 0, false, err
+}
+
+// Blocks until the next request is allowed by the rate limit.
+func (client *Client) wait() {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if delay := time.Until(client.lastRequest.Add(client.Interval)); delay > 0 {
+		time.Sleep(delay)
+	}
+	client.lastRequest = time.Now()
+}
+
+// Path of the cache entry of a release. Product and version must be single
+// path segments, see isPathSegment.
+func (client *Client) cachePath(product string, version string) string {
+	return filepath.Join(client.CacheDirectory, normalizeName(product), version)
+}
+
+// Reports whether a name can be used as a single path segment of the cache
+// without escaping its directory.
+func isPathSegment(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// Reads the cache entry of a release. Returns false if the release is not cached.
+func (client *Client) readCache(product string, version string) (int64, bool, error) {
+	if client.CacheDirectory == "" {
+		return -1, false, nil
+	}
+	content, err := ioutil.ReadFile(client.cachePath(product, version))
+	if err != nil {
+		return -1, false, nil
+	}
+	value := strings.TrimSpace(string(content))
+	if value == notFound {
+		return -1, true, ErrNotFound
+	}
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return -1, false, nil
+	}
+	return timestamp, true, nil
+}
+
+// Writes the cache entry of a release. The entry is written to a temporary file
+// first, so concurrent readers never see a partial entry.
+func (client *Client) writeCache(product string, version string, timestamp int64, err error) {
+	if client.CacheDirectory == "" {
+		return
+	}
+	value := strconv.FormatInt(timestamp, 10)
+	if err == ErrNotFound {
+		value = notFound
+	}
+
+	path := client.cachePath(product, version)
+	if os.MkdirAll(filepath.Dir(path), 0755) != nil {
+		return
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.WriteString(value)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,237 @@
+package cratesio
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const releaseResponse = `{"version": {"num": "0.8.0", "created_at": "2020-10-17T08:00:00.000000+00:00"}}`
+
+const releaseTimestamp = 1602921600
+
+// Server answering requests with the given handler and counting them.
+type testServer struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	requests []*http.Request
+	times    []time.Time
+}
+
+func newTestServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, attempt int)) *testServer {
+	server := &testServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		server.requests = append(server.requests, r)
+		server.times = append(server.times, time.Now())
+		attempt := len(server.requests)
+		server.mutex.Unlock()
+		handler(w, r, attempt)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (server *testServer) count() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return len(server.requests)
+}
+
+func newTestClient(server *testServer) *Client {
+	client := NewClient("test-agent (test@example.com)")
+	client.BaseURL = server.URL
+	client.Interval = 0
+	client.Backoff = time.Millisecond
+	return client
+}
+
+func TestTimestampUserAgent(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		_, _ = w.Write([]byte(releaseResponse))
+	})
+	client := newTestClient(server)
+
+	timestamp, err := client.Timestamp("first-crate", "0.8.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp != releaseTimestamp {
+		t.Errorf("timestamp = %d, want %d", timestamp, releaseTimestamp)
+	}
+	request := server.requests[0]
+	if agent := request.Header.Get("User-Agent"); agent != client.UserAgent {
+		t.Errorf("User-Agent = %q, want %q", agent, client.UserAgent)
+	}
+	if request.URL.Path != "/crates/first-crate/0.8.0" {
+		t.Errorf("path = %q", request.URL.Path)
+	}
+}
+
+func TestTimestampInterval(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		_, _ = w.Write([]byte(releaseResponse))
+	})
+	client := newTestClient(server)
+	client.Interval = 100 * time.Millisecond
+
+	for _, version := range []string{"0.1.0", "0.2.0", "0.3.0"} {
+		if _, err := client.Timestamp("first_crate", version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i < len(server.times); i++ {
+		// The server observes the request slightly after the client sent it.
+		if gap := server.times[i].Sub(server.times[i-1]); gap < 90*time.Millisecond {
+			t.Errorf("requests %d and %d %v apart, want at least %v", i-1, i, gap, client.Interval)
+		}
+	}
+}
+
+func TestTimestampRetriesServerErrors(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		if attempt < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(releaseResponse))
+	})
+	client := newTestClient(server)
+
+	timestamp, err := client.Timestamp("first_crate", "0.8.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp != releaseTimestamp {
+		t.Errorf("timestamp = %d, want %d", timestamp, releaseTimestamp)
+	}
+	if server.count() != 3 {
+		t.Errorf("%d requests, want 3", server.count())
+	}
+}
+
+func TestTimestampRetriesExhausted(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	client := newTestClient(server)
+	client.Retries = 2
+
+	if _, err := client.Timestamp("first_crate", "0.8.0"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if server.count() != 3 {
+		t.Errorf("%d requests, want 3", server.count())
+	}
+}
+
+func TestTimestampRetryAfter(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		if attempt == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(releaseResponse))
+	})
+	client := newTestClient(server)
+
+	if _, err := client.Timestamp("first_crate", "0.8.0"); err != nil {
+		t.Fatal(err)
+	}
+	if server.count() != 2 {
+		t.Fatalf("%d requests, want 2", server.count())
+	}
+	if gap := server.times[1].Sub(server.times[0]); gap < time.Second {
+		t.Errorf("retried after %v, want at least 1s", gap)
+	}
+}
+
+func TestTimestampDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusForbidden} {
+		server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+			w.WriteHeader(status)
+		})
+		client := newTestClient(server)
+
+		if _, err := client.Timestamp("first_crate", "0.8.0"); err == nil || err == ErrNotFound {
+			t.Errorf("status %d: error = %v", status, err)
+		}
+		if server.count() != 1 {
+			t.Errorf("status %d: %d requests, want 1", status, server.count())
+		}
+	}
+}
+
+func TestTimestampNotFoundIsCached(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	client := newTestClient(server)
+	client.CacheDirectory = t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Timestamp("First-Crate", "0.8.0"); err != ErrNotFound {
+			t.Fatalf("error = %v, want ErrNotFound", err)
+		}
+	}
+	if server.count() != 1 {
+		t.Errorf("%d requests, want 1", server.count())
+	}
+	content, err := ioutil.ReadFile(filepath.Join(client.CacheDirectory, "first_crate", "0.8.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != notFound {
+		t.Errorf("cache entry = %q, want %q", content, notFound)
+	}
+}
+
+func TestTimestampCacheHit(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		t.Errorf("unexpected request %s", r.URL)
+	})
+	client := newTestClient(server)
+	client.CacheDirectory = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(client.CacheDirectory, "first_crate"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(client.CacheDirectory, "first_crate", "0.8.0"), []byte("1602921600\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp, err := client.Timestamp("first_crate", "0.8.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp != releaseTimestamp {
+		t.Errorf("timestamp = %d, want %d", timestamp, releaseTimestamp)
+	}
+}
+
+func TestTimestampRejectsPathSeparators(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		_, _ = w.Write([]byte(releaseResponse))
+	})
+	client := newTestClient(server)
+	directory := t.TempDir()
+	client.CacheDirectory = filepath.Join(directory, "cache")
+
+	for _, release := range [][2]string{{"first_crate", "../x"}, {"..", "0.8.0"}, {"first_crate", `a\b`}, {"", "0.8.0"}} {
+		if _, err := client.Timestamp(release[0], release[1]); err == nil {
+			t.Errorf("%q %q: expected an error", release[0], release[1])
+		}
+	}
+	if server.count() != 0 {
+		t.Errorf("%d requests, want 0", server.count())
+	}
+	if _, err := os.Stat(filepath.Join(directory, "x")); !os.IsNotExist(err) {
+		t.Errorf("cache entry written outside of the cache directory")
+	}
+}
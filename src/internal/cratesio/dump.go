@@ -0,0 +1,143 @@
+package cratesio
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Resolves timestamps of releases from the crates.io database dump
+// (https://static.crates.io/db-dump.tar.gz) extracted to a local disk.
+type Dump struct {
+	timestamps map[string]map[string]int64
+}
+
+// Reads crates.csv and versions.csv tables of the extracted database dump.
+// The directory is either the root of the dump or its data directory.
+func LoadDump(directory string) (*Dump, error) {
+	if _, err := os.Stat(filepath.Join(directory, "data", "crates.csv")); err == nil {
+		directory = filepath.Join(directory, "data")
+	}
+
+	crates := make(map[string]string)
+	err := readTable(filepath.Join(directory, "crates.csv"), []string{"id", "name"}, func(row []string) {
+		crates[clone(row[0])] = clone(normalizeName(row[1]))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &Dump{timestamps: make(map[string]map[string]int64)}
+	err = readTable(filepath.Join(directory, "versions.csv"), []string{"crate_id", "num", "created_at"}, func(row []string) {
+		name, ok := crates[row[0]]
+		if !ok {
+			return
+		}
+		timestamp, err := parseTimestamp(row[2])
+		if err != nil {
+			return
+		}
+		if _, ok := dump.timestamps[name]; !ok {
+			dump.timestamps[name] = make(map[string]int64)
+		}
+		dump.timestamps[name][clone(row[1])] = timestamp
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
+
+// Returns the unix timestamp of the release of the given version of a crate.
+func (dump *Dump) Timestamp(product string, version string) (int64, error) {
+	if timestamp, ok := dump.timestamps[normalizeName(product)][version]; ok {
+		return timestamp, nil
+	}
+	return -1, errors.New("no release of " + product + " " + version + " in the dump")
+}
+
+// Reads a CSV table with a header and passes the given columns of every row
+// in order to the callback.
+func readTable(path string, columns []string, callback func(row []string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return errors.New(path + ": " + err.Error())
+	}
+	indices := make([]int, len(columns))
+	for i, column := range columns {
+		indices[i] = -1
+		for j, name := range header {
+			if name == column {
+				indices[i] = j
+			}
+		}
+		if indices[i] == -1 {
+			return errors.New(path + ": missing column " + column)
+		}
+	}
+
+	row := make([]string, len(columns))
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.New(path + ": " + err.Error())
+		}
+		for i, index := range indices {
+			if index >= len(record) {
+				row[i] = ""
+			} else {
+				row[i] = record[index]
+			}
+		}
+		callback(row)
+	}
+}
+
+// Copies the string, so it does not keep the whole line of the table in memory.
+func clone(value string) string {
+	return string([]byte(value))
+}
+
+// Crate names on crates.io are case insensitive and do not distinguish
+// between - and _, while crate names in call graphs use _.
+func normalizeName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+// Layouts of timestamps used by the crates.io API and the database dump.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// Parses a crates.io timestamp to unix time.
+func parseTimestamp(value string) (int64, error) {
+	if value == "" {
+		return -1, errors.New("missing timestamp")
+	}
+	for _, layout := range timestampLayouts {
+		if timestamp, err := time.Parse(layout, value); err == nil {
+			return timestamp.Unix(), nil
+		}
+	}
+	return -1, errors.New("unknown timestamp format " + value)
+}
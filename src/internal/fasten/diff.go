@@ -0,0 +1,206 @@
+package fasten
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Differences between two call graphs. Methods and calls are identified by URIs,
+// so graphs with different ids can be compared. All lists are sorted.
+type Difference struct {
+	AddedNamespaces      []string     `json:"addedNamespaces"`
+	RemovedNamespaces    []string     `json:"removedNamespaces"`
+	AddedMethods         []string     `json:"addedMethods"`
+	RemovedMethods       []string     `json:"removedMethods"`
+	AddedInternalCalls   []Call       `json:"addedInternalCalls"`
+	RemovedInternalCalls []Call       `json:"removedInternalCalls"`
+	AddedExternalCalls   []Call       `json:"addedExternalCalls"`
+	RemovedExternalCalls []Call       `json:"removedExternalCalls"`
+	AddedDependencies    []Dependency `json:"addedDependencies"`
+	RemovedDependencies  []Dependency `json:"removedDependencies"`
+}
+
+// Call between two methods identified by their URIs.
+type Call struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// Compares two call graphs, e.g. two versions of a crate or the outputs of two
+// builds of the converter. Elements only present in newJSON are added, elements
+// only present in oldJSON are removed.
+func Diff(oldJSON *JSON, newJSON *JSON) Difference {
+	var difference Difference
+	difference.AddedNamespaces, difference.RemovedNamespaces = diffStrings(namespaceSet(oldJSON), namespaceSet(newJSON))
+	difference.AddedMethods, difference.RemovedMethods = diffStrings(methodSet(oldJSON), methodSet(newJSON))
+	difference.AddedInternalCalls, difference.RemovedInternalCalls = diffCalls(internalCallSet(oldJSON), internalCallSet(newJSON))
+	difference.AddedExternalCalls, difference.RemovedExternalCalls = diffCalls(externalCallSet(oldJSON), externalCallSet(newJSON))
+	difference.AddedDependencies, difference.RemovedDependencies = diffDependencies(oldJSON.Depset, newJSON.Depset)
+	return difference
+}
+
+// Checks if the graphs do not differ.
+func (difference Difference) IsEmpty() bool {
+	return len(difference.AddedNamespaces) == 0 && len(difference.RemovedNamespaces) == 0 &&
+		len(difference.AddedMethods) == 0 && len(difference.RemovedMethods) == 0 &&
+		len(difference.AddedInternalCalls) == 0 && len(difference.RemovedInternalCalls) == 0 &&
+		len(difference.AddedExternalCalls) == 0 && len(difference.RemovedExternalCalls) == 0 &&
+		len(difference.AddedDependencies) == 0 && len(difference.RemovedDependencies) == 0
+}
+
+// Returns the namespaces of the CHA.
+func namespaceSet(fastenJSON *JSON) map[string]struct{} {
+	namespaces := make(map[string]struct{})
+	for namespace := range fastenJSON.Cha {
+		namespaces[namespace] = struct{}{}
+	}
+	return namespaces
+}
+
+// Returns the URIs of all methods of the CHA.
+func methodSet(fastenJSON *JSON) map[string]struct{} {
+	methods := make(map[string]struct{})
+	for _, typeValue := range fastenJSON.Cha {
+		for _, method := range typeValue.Methods {
+			methods[method] = struct{}{}
+		}
+	}
+	return methods
+}
+
+// Returns the internal calls with ids replaced by URIs of the methods.
+func internalCallSet(fastenJSON *JSON) map[Call]struct{} {
+	uris := methodURIs(fastenJSON)
+	calls := make(map[Call]struct{})
+	for _, call := range fastenJSON.Graph.InternalCalls {
+		source, _ := toId(call[0])
+		target, _ := toId(call[1])
+		calls[Call{Source: uris.get(source), Target: uris.get(target)}] = struct{}{}
+	}
+	return calls
+}
+
+// Returns the external calls with source ids replaced by URIs of the methods.
+func externalCallSet(fastenJSON *JSON) map[Call]struct{} {
+	uris := methodURIs(fastenJSON)
+	calls := make(map[Call]struct{})
+	for _, call := range fastenJSON.Graph.ExternalCalls {
+		source, _ := toSourceId(call[0])
+		target, _ := call[1].(string)
+		calls[Call{Source: uris.get(source), Target: target}] = struct{}{}
+	}
+	return calls
+}
+
+// URIs of the methods of a graph by id.
+type uriIndex map[int64]string
+
+// Returns the URIs of all methods of the CHA by id.
+func methodURIs(fastenJSON *JSON) uriIndex {
+	uris := make(uriIndex)
+	for _, typeValue := range fastenJSON.Cha {
+		for id, method := range typeValue.Methods {
+			uris[id] = method
+		}
+	}
+	return uris
+}
+
+// Returns the URI of the method, or the id itself if it is not defined in the CHA.
+func (uris uriIndex) get(id int64) string {
+	if uri, exists := uris[id]; exists {
+		return uri
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// Returns the sorted elements only present in the new set and only present in the old set.
+func diffStrings(oldSet map[string]struct{}, newSet map[string]struct{}) ([]string, []string) {
+	added, removed := []string{}, []string{}
+	for element := range newSet {
+		if _, exists := oldSet[element]; !exists {
+			added = append(added, element)
+		}
+	}
+	for element := range oldSet {
+		if _, exists := newSet[element]; !exists {
+			removed = append(removed, element)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// Returns the sorted calls only present in the new set and only present in the old set.
+func diffCalls(oldSet map[Call]struct{}, newSet map[Call]struct{}) ([]Call, []Call) {
+	added, removed := []Call{}, []Call{}
+	for call := range newSet {
+		if _, exists := oldSet[call]; !exists {
+			added = append(added, call)
+		}
+	}
+	for call := range oldSet {
+		if _, exists := newSet[call]; !exists {
+			removed = append(removed, call)
+		}
+	}
+	sortCalls(added)
+	sortCalls(removed)
+	return added, removed
+}
+
+// Sorts calls by source and target.
+func sortCalls(calls []Call) {
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].Source != calls[j].Source {
+			return calls[i].Source < calls[j].Source
+		}
+		return calls[i].Target < calls[j].Target
+	})
+}
+
+// Returns the dependencies only present in the new depset and only present in the old depset,
+// sorted by product.
+func diffDependencies(oldDepset [][]Dependency, newDepset [][]Dependency) ([]Dependency, []Dependency) {
+	oldDependencies, newDependencies := dependencySet(oldDepset), dependencySet(newDepset)
+	added, removed := []Dependency{}, []Dependency{}
+	for key, dependency := range newDependencies {
+		if _, exists := oldDependencies[key]; !exists {
+			added = append(added, dependency)
+		}
+	}
+	for key, dependency := range oldDependencies {
+		if _, exists := newDependencies[key]; !exists {
+			removed = append(removed, dependency)
+		}
+	}
+	sortDependencies(added)
+	sortDependencies(removed)
+	return added, removed
+}
+
+// Returns the dependencies of all inner lists of the depset by their key.
+func dependencySet(depset [][]Dependency) map[string]Dependency {
+	dependencies := make(map[string]Dependency)
+	for _, inner := range depset {
+		for _, dependency := range inner {
+			dependencies[dependency.key()] = dependency
+		}
+	}
+	return dependencies
+}
+
+// Returns a key identifying the dependency with all its fields.
+func (dependency Dependency) key() string {
+	return strings.Join([]string{dependency.Forge, dependency.Product, strings.Join(dependency.Constraints, ","),
+		dependency.Kind, strconv.FormatBool(dependency.Optional), dependency.Resolved}, " ")
+}
+
+// Sorts dependencies by their key.
+func sortDependencies(dependencies []Dependency) {
+	sort.Slice(dependencies, func(i, j int) bool {
+		return dependencies[i].Product+" "+dependencies[i].key() < dependencies[j].Product+" "+dependencies[j].key()
+	})
+}
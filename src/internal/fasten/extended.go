@@ -0,0 +1,153 @@
+package fasten
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Call graph in the extended revision call graph format shared with the Fasten
+// plugins of other languages. Methods of all types are nodes numbered across
+// internal and external types.
+type ExtendedJSON struct {
+	Product   string            `json:"product"`
+	Forge     string            `json:"forge"`
+	Generator string            `json:"generator"`
+	Depset    [][]Dependency    `json:"depset"`
+	Version   string            `json:"version"`
+	Modules   Modules           `json:"modules"`
+	Nodes     int64             `json:"nodes"`
+	Graph     ExtendedCallGraph `json:"graph"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// Types declared in the product and types of other products called by it.
+type Modules struct {
+	InternalTypes map[string]ExtendedType `json:"internalTypes"`
+	ExternalTypes map[string]ExtendedType `json:"externalTypes"`
+}
+
+type ExtendedType struct {
+	SourceFile      string         `json:"sourceFile"`
+	SuperInterfaces []string       `json:"superInterfaces"`
+	SuperClasses    []string       `json:"superClasses"`
+	Methods         map[int64]Node `json:"methods"`
+}
+
+// Method of a type with its metadata.
+type Node struct {
+	URI      string                 `json:"uri"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// Calls in format [source id, target id, call-site metadata]. Resolved calls
+// point to nodes of other products and are filled in by stitching.
+type ExtendedCallGraph struct {
+	InternalCalls [][]interface{} `json:"internalCalls"`
+	ExternalCalls [][]interface{} `json:"externalCalls"`
+	ResolvedCalls [][]interface{} `json:"resolvedCalls"`
+}
+
+// Converts this extended fastenJSON to JSON format
+func (extendedJSON *ExtendedJSON) ToJSON() []byte {
+	fasten, _ := json.Marshal(extendedJSON)
+	return fasten
+}
+
+// Converts this fastenJSON to the extended format. Targets of external calls become
+// nodes of external types numbered after the methods of the CHA.
+func (fastenJSON *JSON) ToExtended() ExtendedJSON {
+	extendedJSON := ExtendedJSON{
+		Product:   fastenJSON.Product,
+		Forge:     fastenJSON.Forge,
+		Generator: fastenJSON.Generator,
+		Depset:    fastenJSON.Depset,
+		Version:   fastenJSON.Version,
+		Modules: Modules{
+			InternalTypes: make(map[string]ExtendedType),
+			ExternalTypes: make(map[string]ExtendedType),
+		},
+		Graph: ExtendedCallGraph{
+			InternalCalls: [][]interface{}{},
+			ExternalCalls: [][]interface{}{},
+			ResolvedCalls: [][]interface{}{},
+		},
+		Timestamp: fastenJSON.Timestamp,
+	}
+
+	for namespace, typeValue := range fastenJSON.Cha {
+		extendedType := newExtendedType(typeValue.SourceFile, typeValue.SuperInterfaces, typeValue.SuperClasses)
+		for id, uri := range typeValue.Methods {
+			metadata := typeValue.MethodMetadata[id]
+			if metadata == nil {
+				metadata = map[string]interface{}{}
+			}
+			extendedType.Methods[id] = Node{URI: uri, Metadata: metadata}
+			if id >= extendedJSON.Nodes {
+				extendedJSON.Nodes = id + 1
+			}
+		}
+		extendedJSON.Modules.InternalTypes[namespace] = extendedType
+	}
+
+	for _, call := range fastenJSON.Graph.InternalCalls {
+		extendedJSON.Graph.InternalCalls = append(extendedJSON.Graph.InternalCalls,
+			[]interface{}{call[0], call[1], toMetadata(call[2])})
+	}
+
+	externalNodes := make(map[string]int64)
+	for _, call := range fastenJSON.Graph.ExternalCalls {
+		source, _ := strconv.ParseInt(call[0].(string), 10, 64)
+		target := call[1].(string)
+		id, exists := externalNodes[target]
+		if !exists {
+			id = extendedJSON.Nodes
+			extendedJSON.Nodes++
+			externalNodes[target] = id
+
+			namespace := target
+			if uri, err := ParseURI(target); err == nil {
+				namespace = uri.Type().String()
+			}
+			if _, exists := extendedJSON.Modules.ExternalTypes[namespace]; !exists {
+				extendedJSON.Modules.ExternalTypes[namespace] = newExtendedType("", nil, nil)
+			}
+			extendedJSON.Modules.ExternalTypes[namespace].Methods[id] = Node{URI: target, Metadata: map[string]interface{}{}}
+		}
+		extendedJSON.Graph.ExternalCalls = append(extendedJSON.Graph.ExternalCalls,
+			[]interface{}{source, id, toMetadata(call[2])})
+	}
+
+	return extendedJSON
+}
+
+// Creates an extended type without methods.
+func newExtendedType(sourceFile string, superInterfaces []string, superClasses []string) ExtendedType {
+	if superInterfaces == nil {
+		superInterfaces = []string{}
+	}
+	if superClasses == nil {
+		superClasses = []string{}
+	}
+	return ExtendedType{
+		SourceFile:      sourceFile,
+		SuperInterfaces: superInterfaces,
+		SuperClasses:    superClasses,
+		Methods:         map[int64]Node{},
+	}
+}
+
+// Converts metadata of a call to a generic map.
+func toMetadata(value interface{}) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	switch value := value.(type) {
+	case map[string]string:
+		for key, entry := range value {
+			metadata[key] = entry
+		}
+	case map[string]interface{}:
+		for key, entry := range value {
+			metadata[key] = entry
+		}
+	}
+	return metadata
+}
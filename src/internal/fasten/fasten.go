@@ -15,27 +15,31 @@ type JSON struct {
 	Graph     CallGraph       `json:"graph"`
 	Timestamp int64           `json:"timestamp"`
 
-	Counter               int64                         `json:"-"`
-	DuplicateCHA          map[string]int64              `json:"-"`
-	DuplicateInternalCall map[int64]map[int64]struct{}  `json:"-"`
-	DuplicateExternalCall map[int64]map[string]struct{} `json:"-"`
+	Counter               int64                    `json:"-"`
+	DuplicateCHA          map[string]int64         `json:"-"`
+	DuplicateInternalCall map[int64]map[int64]int  `json:"-"`
+	DuplicateExternalCall map[int64]map[string]int `json:"-"`
 }
 
 type Dependency struct {
 	Product     string   `json:"product"`
 	Forge       string   `json:"forge"`
 	Constraints []string `json:"constraints,nilasempty"`
+	Kind        string   `json:"kind,omitempty"`
+	Optional    bool     `json:"optional,omitempty"`
+	Resolved    string   `json:"resolved,omitempty"`
 }
 
 type Type struct {
-	Methods         map[int64]string `json:"methods"`
-	SuperInterfaces []string         `json:"superInterfaces"`
-	SourceFile      string           `json:"sourceFile"`
-	SuperClasses    []string         `json:"superClasses,nilasempty"`
+	Methods         map[int64]string                 `json:"methods"`
+	SuperInterfaces []string                         `json:"superInterfaces"`
+	SourceFile      string                           `json:"sourceFile"`
+	SuperClasses    []string                         `json:"superClasses,nilasempty"`
+	MethodMetadata  map[int64]map[string]interface{} `json:"methodMetadata,omitempty"`
 }
 
 type CallGraph struct {
-	InternalCalls [][]int64       `json:"internalCalls"`
+	InternalCalls [][]interface{} `json:"internalCalls"`
 	ExternalCalls [][]interface{} `json:"externalCalls"`
 }
 
@@ -52,28 +56,21 @@ func (fastenJSON *JSON) IsEmpty() bool {
 		fastenJSON.Graph.ExternalCalls == nil
 }
 
-// Adds a dependency too the current JSON depset.
+// Adds a dependency too the current JSON depset. Each version of a product
+// is added as a separate dependency.
 func (fastenJSON *JSON) AddDependency(target *JSON) {
 	if target.Product == "" {
 		return
 	}
+	constraint := "[" + target.Version + "]"
 
 	for _, inner := range fastenJSON.Depset {
 		for _, dependency := range inner {
-			if dependency.Product == target.Product {
-				found := false
-				if target.Version == "" {
-					return
-				}
-				for _, constraint := range dependency.Constraints {
-					if constraint == target.Version {
-						found = true
-						break
-					}
-				}
-				if found {
-					return
-				} else if target.Version != "" {
+			if dependency.Product != target.Product {
+				continue
+			}
+			for _, existing := range dependency.Constraints {
+				if existing == constraint {
 					return
 				}
 			}
@@ -85,12 +82,13 @@ func (fastenJSON *JSON) AddDependency(target *JSON) {
 	fastenJSON.Depset[0] = append(fastenJSON.Depset[0], Dependency{
 		Product:     target.Product,
 		Forge:       "cratesio",
-		Constraints: []string{"[" + target.Version + "]"},
+		Constraints: []string{constraint},
 	})
 }
 
-// Add method to Class Hierarchy.
-func (fastenJSON *JSON) AddMethodToCHA(namespace string, methodName string) int64 {
+// Add method with its metadata to Class Hierarchy. Metadata of a method
+// which is already present is kept.
+func (fastenJSON *JSON) AddMethodToCHA(namespace string, methodName string, metadata map[string]interface{}) int64 {
 	if methodName == "" {
 		return -1
 	}
@@ -98,7 +96,15 @@ func (fastenJSON *JSON) AddMethodToCHA(namespace string, methodName string) int6
 	fastenJSON.initializeCHANamespace(namespace)
 
 	if _, exists := fastenJSON.DuplicateCHA[methodName]; !exists {
-		fastenJSON.Cha[namespace].Methods[fastenJSON.Counter] = methodName
+		typeValue := fastenJSON.Cha[namespace]
+		typeValue.Methods[fastenJSON.Counter] = methodName
+		if len(metadata) > 0 {
+			if typeValue.MethodMetadata == nil {
+				typeValue.MethodMetadata = map[int64]map[string]interface{}{}
+				fastenJSON.Cha[namespace] = typeValue
+			}
+			typeValue.MethodMetadata[fastenJSON.Counter] = metadata
+		}
 		fastenJSON.DuplicateCHA[methodName] = fastenJSON.Counter
 		fastenJSON.Counter++
 	}
@@ -106,6 +112,28 @@ func (fastenJSON *JSON) AddMethodToCHA(namespace string, methodName string) int6
 	return fastenJSON.DuplicateCHA[methodName]
 }
 
+// Sets an entry of the metadata of the method with the id in the namespace. The metadata
+// is copied first, as methods added with the same metadata share it.
+func (fastenJSON *JSON) SetMethodMetadata(namespace string, id int64, key string, value interface{}) {
+	typeValue, exists := fastenJSON.Cha[namespace]
+	if !exists {
+		return
+	}
+	if _, exists = typeValue.Methods[id]; !exists {
+		return
+	}
+	metadata := map[string]interface{}{}
+	for existingKey, existingValue := range typeValue.MethodMetadata[id] {
+		metadata[existingKey] = existingValue
+	}
+	metadata[key] = value
+	if typeValue.MethodMetadata == nil {
+		typeValue.MethodMetadata = map[int64]map[string]interface{}{}
+		fastenJSON.Cha[namespace] = typeValue
+	}
+	typeValue.MethodMetadata[id] = metadata
+}
+
 // Add interface to Class Hierarchy.
 func (fastenJSON *JSON) AddInterfaceToCHA(namespace string, interfaceName string) {
 	if interfaceName == "" {
@@ -124,6 +152,24 @@ func (fastenJSON *JSON) AddInterfaceToCHA(namespace string, interfaceName string
 	fastenJSON.Cha[namespace] = typeValue
 }
 
+// Add super class to Class Hierarchy.
+func (fastenJSON *JSON) AddSuperClassToCHA(namespace string, superClass string) {
+	if superClass == "" {
+		return
+	}
+
+	fastenJSON.initializeCHANamespace(namespace)
+
+	typeValue := fastenJSON.Cha[namespace]
+	for _, existing := range typeValue.SuperClasses {
+		if existing == superClass {
+			return
+		}
+	}
+	typeValue.SuperClasses = append(typeValue.SuperClasses, superClass)
+	fastenJSON.Cha[namespace] = typeValue
+}
+
 // Add filename to Class Hierarchy.
 func (fastenJSON *JSON) AddFilenameToCHA(namespace string, filename string) {
 	if filename == "" {
@@ -149,20 +195,32 @@ func (fastenJSON *JSON) initializeCHANamespace(namespace string) {
 	}
 }
 
-// Add internal call to the Graph.
-func (fastenJSON *JSON) AddInternalCall(sourceId int64, targetId int64) {
-	if _, exists := fastenJSON.DuplicateInternalCall[sourceId][targetId]; !exists {
-		fastenJSON.Graph.InternalCalls = append(fastenJSON.Graph.InternalCalls, []int64{sourceId, targetId})
-		newEntry := map[int64]struct{}{targetId: {}}
-		fastenJSON.DuplicateInternalCall[sourceId] = newEntry
+// Add internal call to the Graph. Returns the call-site metadata of the call,
+// which is shared by all calls between the same methods.
+func (fastenJSON *JSON) AddInternalCall(sourceId int64, targetId int64) map[string]interface{} {
+	if index, exists := fastenJSON.DuplicateInternalCall[sourceId][targetId]; exists {
+		return fastenJSON.Graph.InternalCalls[index][2].(map[string]interface{})
+	}
+	metadata := map[string]interface{}{}
+	if _, exists := fastenJSON.DuplicateInternalCall[sourceId]; !exists {
+		fastenJSON.DuplicateInternalCall[sourceId] = map[int64]int{}
 	}
+	fastenJSON.DuplicateInternalCall[sourceId][targetId] = len(fastenJSON.Graph.InternalCalls)
+	fastenJSON.Graph.InternalCalls = append(fastenJSON.Graph.InternalCalls, []interface{}{sourceId, targetId, metadata})
+	return metadata
 }
 
-// Add external call to the Graph.
-func (fastenJSON *JSON) AddExternalCall(sourceId int64, target string, metadata map[string]string) {
-	if _, exists := fastenJSON.DuplicateExternalCall[sourceId][target]; !exists {
-		fastenJSON.Graph.ExternalCalls = append(fastenJSON.Graph.ExternalCalls, []interface{}{strconv.FormatInt(sourceId, 10), target, metadata})
-		newEntry := map[string]struct{}{target: {}}
-		fastenJSON.DuplicateExternalCall[sourceId] = newEntry
+// Add external call to the Graph. Returns the call-site metadata of the call,
+// which is shared by all calls between the same methods.
+func (fastenJSON *JSON) AddExternalCall(sourceId int64, target string) map[string]interface{} {
+	if index, exists := fastenJSON.DuplicateExternalCall[sourceId][target]; exists {
+		return fastenJSON.Graph.ExternalCalls[index][2].(map[string]interface{})
+	}
+	metadata := map[string]interface{}{}
+	if _, exists := fastenJSON.DuplicateExternalCall[sourceId]; !exists {
+		fastenJSON.DuplicateExternalCall[sourceId] = map[string]int{}
 	}
+	fastenJSON.DuplicateExternalCall[sourceId][target] = len(fastenJSON.Graph.ExternalCalls)
+	fastenJSON.Graph.ExternalCalls = append(fastenJSON.Graph.ExternalCalls, []interface{}{strconv.FormatInt(sourceId, 10), target, metadata})
+	return metadata
 }
@@ -0,0 +1,248 @@
+package fasten
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+)
+
+// Reads a call graph written in the flat or the extended format. Numbers are
+// normalized to the types used by the converter and the indexes used to add
+// methods and calls are rebuilt, so the graph can be extended like a converted one.
+// Resolved calls of the extended format are dropped.
+func Load(reader io.Reader) (*JSON, error) {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+
+	var fastenJSON *JSON
+	if _, extended := fields["modules"]; extended {
+		var extendedJSON ExtendedJSON
+		if err = decode(content, &extendedJSON); err != nil {
+			return nil, err
+		}
+		fastenJSON, err = extendedJSON.toFlat()
+	} else {
+		fastenJSON = &JSON{}
+		err = decode(content, fastenJSON)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = fastenJSON.normalize(); err != nil {
+		return nil, err
+	}
+	fastenJSON.rebuildIndexes()
+	return fastenJSON, nil
+}
+
+// Decodes JSON keeping numbers as json.Number.
+func decode(content []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
+
+// Converts calls and metadata to the types created by the converter: internal calls
+// to [int64, int64, metadata], external calls to [string, string, metadata].
+func (fastenJSON *JSON) normalize() error {
+	if fastenJSON.Cha == nil {
+		fastenJSON.Cha = map[string]Type{}
+	}
+	for namespace, typeValue := range fastenJSON.Cha {
+		if typeValue.Methods == nil {
+			typeValue.Methods = map[int64]string{}
+		}
+		if typeValue.SuperInterfaces == nil {
+			typeValue.SuperInterfaces = []string{}
+		}
+		if typeValue.SuperClasses == nil {
+			typeValue.SuperClasses = []string{}
+		}
+		for _, metadata := range typeValue.MethodMetadata {
+			normalizeMetadata(metadata)
+		}
+		fastenJSON.Cha[namespace] = typeValue
+	}
+
+	internalCalls := make([][]interface{}, 0, len(fastenJSON.Graph.InternalCalls))
+	for i, call := range fastenJSON.Graph.InternalCalls {
+		if len(call) != 2 && len(call) != 3 {
+			return errors.New("internal call " + strconv.Itoa(i) + " is not a pair or triple")
+		}
+		source, sourceOk := toId(call[0])
+		target, targetOk := toId(call[1])
+		if !sourceOk || !targetOk {
+			return errors.New("internal call " + strconv.Itoa(i) + " does not consist of ids")
+		}
+		if len(call) == 3 && !isMetadata(call[2]) {
+			return errors.New("metadata of internal call " + strconv.Itoa(i) + " is not an object")
+		}
+		internalCalls = append(internalCalls, []interface{}{source, target, callMetadata(call)})
+	}
+	fastenJSON.Graph.InternalCalls = internalCalls
+
+	externalCalls := make([][]interface{}, 0, len(fastenJSON.Graph.ExternalCalls))
+	for i, call := range fastenJSON.Graph.ExternalCalls {
+		if len(call) != 2 && len(call) != 3 {
+			return errors.New("external call " + strconv.Itoa(i) + " is not a pair or triple")
+		}
+		source, sourceOk := toSourceId(call[0])
+		target, targetOk := call[1].(string)
+		if !sourceOk || !targetOk {
+			return errors.New("external call " + strconv.Itoa(i) + " does not consist of an id and a URI")
+		}
+		if len(call) == 3 && !isMetadata(call[2]) {
+			return errors.New("metadata of external call " + strconv.Itoa(i) + " is not an object")
+		}
+		externalCalls = append(externalCalls, []interface{}{strconv.FormatInt(source, 10), target, callMetadata(call)})
+	}
+	fastenJSON.Graph.ExternalCalls = externalCalls
+	return nil
+}
+
+// Converts the source of an external call, an id written as a string, to int64.
+func toSourceId(value interface{}) (int64, bool) {
+	if id, ok := value.(string); ok {
+		source, err := strconv.ParseInt(id, 10, 64)
+		return source, err == nil
+	}
+	return toId(value)
+}
+
+// Returns the normalized metadata of a call, empty if the call has none. Calls
+// written before call metadata was added are pairs.
+func callMetadata(call []interface{}) map[string]interface{} {
+	if len(call) < 3 {
+		return map[string]interface{}{}
+	}
+	metadata := toMetadata(call[2])
+	normalizeMetadata(metadata)
+	return metadata
+}
+
+// Converts numbers of the metadata to int64 or float64 and nested objects recursively.
+func normalizeMetadata(metadata map[string]interface{}) {
+	for key, value := range metadata {
+		metadata[key] = normalizeValue(value)
+	}
+}
+
+// Converts json.Number to int64 or float64 in the value.
+func normalizeValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		if number, err := value.Int64(); err == nil {
+			return number
+		}
+		number, _ := value.Float64()
+		return number
+	case map[string]interface{}:
+		normalizeMetadata(value)
+	case []interface{}:
+		for i := range value {
+			value[i] = normalizeValue(value[i])
+		}
+	}
+	return value
+}
+
+// Rebuilds Counter and the indexes of methods and calls from the CHA and the graph.
+func (fastenJSON *JSON) rebuildIndexes() {
+	fastenJSON.Counter = 0
+	fastenJSON.DuplicateCHA = make(map[string]int64)
+	fastenJSON.DuplicateInternalCall = make(map[int64]map[int64]int)
+	fastenJSON.DuplicateExternalCall = make(map[int64]map[string]int)
+
+	for _, typeValue := range fastenJSON.Cha {
+		for id, method := range typeValue.Methods {
+			fastenJSON.DuplicateCHA[method] = id
+			if id >= fastenJSON.Counter {
+				fastenJSON.Counter = id + 1
+			}
+		}
+	}
+	for i, call := range fastenJSON.Graph.InternalCalls {
+		source, target := call[0].(int64), call[1].(int64)
+		if _, exists := fastenJSON.DuplicateInternalCall[source]; !exists {
+			fastenJSON.DuplicateInternalCall[source] = map[int64]int{}
+		}
+		fastenJSON.DuplicateInternalCall[source][target] = i
+	}
+	for i, call := range fastenJSON.Graph.ExternalCalls {
+		source, _ := toSourceId(call[0])
+		target := call[1].(string)
+		if _, exists := fastenJSON.DuplicateExternalCall[source]; !exists {
+			fastenJSON.DuplicateExternalCall[source] = map[string]int{}
+		}
+		fastenJSON.DuplicateExternalCall[source][target] = i
+	}
+}
+
+// Converts the extended graph back to the flat format. Nodes of external types
+// become target URIs of external calls.
+func (extendedJSON *ExtendedJSON) toFlat() (*JSON, error) {
+	fastenJSON := &JSON{
+		Product:   extendedJSON.Product,
+		Forge:     extendedJSON.Forge,
+		Generator: extendedJSON.Generator,
+		Depset:    extendedJSON.Depset,
+		Version:   extendedJSON.Version,
+		Cha:       map[string]Type{},
+		Graph: CallGraph{
+			InternalCalls: extendedJSON.Graph.InternalCalls,
+			ExternalCalls: make([][]interface{}, 0, len(extendedJSON.Graph.ExternalCalls)),
+		},
+		Timestamp: extendedJSON.Timestamp,
+	}
+
+	for namespace, extendedType := range extendedJSON.Modules.InternalTypes {
+		typeValue := Type{
+			Methods:         map[int64]string{},
+			SuperInterfaces: extendedType.SuperInterfaces,
+			SourceFile:      extendedType.SourceFile,
+			SuperClasses:    extendedType.SuperClasses,
+		}
+		for id, node := range extendedType.Methods {
+			typeValue.Methods[id] = node.URI
+			if len(node.Metadata) > 0 {
+				if typeValue.MethodMetadata == nil {
+					typeValue.MethodMetadata = map[int64]map[string]interface{}{}
+				}
+				typeValue.MethodMetadata[id] = node.Metadata
+			}
+		}
+		fastenJSON.Cha[namespace] = typeValue
+	}
+
+	externalNodes := make(map[int64]string)
+	for _, extendedType := range extendedJSON.Modules.ExternalTypes {
+		for id, node := range extendedType.Methods {
+			externalNodes[id] = node.URI
+		}
+	}
+	for i, call := range extendedJSON.Graph.ExternalCalls {
+		if len(call) != 3 {
+			return nil, errors.New("external call " + strconv.Itoa(i) + " is not a triple")
+		}
+		target, ok := toId(call[1])
+		if !ok {
+			return nil, errors.New("external call " + strconv.Itoa(i) + " does not point to an id")
+		}
+		uri, exists := externalNodes[target]
+		if !exists {
+			return nil, errors.New("external call " + strconv.Itoa(i) + " points to unknown node " + strconv.FormatInt(target, 10))
+		}
+		fastenJSON.Graph.ExternalCalls = append(fastenJSON.Graph.ExternalCalls, []interface{}{call[0], uri, call[2]})
+	}
+	return fastenJSON, nil
+}
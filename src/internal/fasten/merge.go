@@ -0,0 +1,166 @@
+package fasten
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// Merges graphs of the same product and version, e.g. built with different features
+// or for different targets, into one graph. Methods are renumbered and identified by
+// their URI, calls and dependencies are deduplicated. Metadata of methods and calls
+// is merged. Calls may be pairs without metadata as written by older versions.
+func Merge(graphs ...*JSON) (*JSON, error) {
+	if len(graphs) == 0 {
+		return nil, errors.New("no graphs to merge")
+	}
+	first := graphs[0]
+	for _, graph := range graphs[1:] {
+		if graph.Product != first.Product || graph.Forge != first.Forge || graph.Version != first.Version {
+			return nil, errors.New("cannot merge " + graph.Product + "$" + graph.Version +
+				" into " + first.Product + "$" + first.Version)
+		}
+	}
+
+	merged := &JSON{
+		Product:   first.Product,
+		Forge:     first.Forge,
+		Generator: first.Generator,
+		Depset:    [][]Dependency{},
+		Version:   first.Version,
+		Cha:       map[string]Type{},
+		Graph: CallGraph{
+			InternalCalls: make([][]interface{}, 0),
+			ExternalCalls: make([][]interface{}, 0),
+		},
+		Timestamp:             -1,
+		DuplicateCHA:          make(map[string]int64),
+		DuplicateInternalCall: make(map[int64]map[int64]int),
+		DuplicateExternalCall: make(map[int64]map[string]int),
+	}
+	for _, graph := range graphs {
+		if merged.Timestamp == -1 {
+			merged.Timestamp = graph.Timestamp
+		}
+		ids := merged.mergeCHA(graph)
+		for _, call := range graph.Graph.InternalCalls {
+			if len(call) < 2 {
+				return nil, errors.New("internal call without source and target")
+			}
+			source, sourceOk := toId(call[0])
+			target, targetOk := toId(call[1])
+			if _, exists := ids[source]; !exists || !sourceOk {
+				return nil, errors.New("internal call from unknown id " + strconv.FormatInt(source, 10))
+			}
+			if _, exists := ids[target]; !exists || !targetOk {
+				return nil, errors.New("internal call to unknown id " + strconv.FormatInt(target, 10))
+			}
+			metadata := merged.AddInternalCall(ids[source], ids[target])
+			if len(call) > 2 {
+				mergeCallMetadata(metadata, call[2])
+			}
+		}
+		for _, call := range graph.Graph.ExternalCalls {
+			if len(call) < 2 {
+				return nil, errors.New("external call without source and target")
+			}
+			source, sourceOk := toSourceId(call[0])
+			if _, exists := ids[source]; !exists || !sourceOk {
+				return nil, errors.New("external call from unknown id " + strconv.FormatInt(source, 10))
+			}
+			target, ok := call[1].(string)
+			if !ok {
+				return nil, errors.New("external call from id " + strconv.FormatInt(source, 10) + " to a target which is not a URI")
+			}
+			metadata := merged.AddExternalCall(ids[source], target)
+			if len(call) > 2 {
+				mergeCallMetadata(metadata, call[2])
+			}
+		}
+		merged.mergeDepset(graph.Depset)
+	}
+	return merged, nil
+}
+
+// Adds namespaces and methods of the graph to the CHA. Returns the new ids of the methods.
+func (fastenJSON *JSON) mergeCHA(graph *JSON) map[int64]int64 {
+	ids := make(map[int64]int64)
+	for _, namespace := range sortedNamespaces(graph.Cha) {
+		typeValue := graph.Cha[namespace]
+		fastenJSON.initializeCHANamespace(namespace)
+		fastenJSON.AddFilenameToCHA(namespace, typeValue.SourceFile)
+		for _, trait := range typeValue.SuperInterfaces {
+			fastenJSON.AddInterfaceToCHA(namespace, trait)
+		}
+		for _, superClass := range typeValue.SuperClasses {
+			fastenJSON.AddSuperClassToCHA(namespace, superClass)
+		}
+		for _, id := range sortedIds(typeValue.Methods) {
+			ids[id] = fastenJSON.AddMethodToCHA(namespace, typeValue.Methods[id], nil)
+			if typeValue.MethodMetadata[id] != nil {
+				fastenJSON.mergeMethodMetadata(namespace, ids[id], toMetadata(typeValue.MethodMetadata[id]))
+			}
+		}
+	}
+	return ids
+}
+
+// Merges metadata of a method into the metadata of the same method in another graph.
+// Entries present in both are kept from the graph merged first.
+func (fastenJSON *JSON) mergeMethodMetadata(namespace string, id int64, metadata map[string]interface{}) {
+	if len(metadata) == 0 {
+		return
+	}
+	typeValue := fastenJSON.Cha[namespace]
+	merged := make(map[string]interface{})
+	for key, entry := range metadata {
+		merged[key] = entry
+	}
+	for key, entry := range typeValue.MethodMetadata[id] {
+		merged[key] = entry
+	}
+	if typeValue.MethodMetadata == nil {
+		typeValue.MethodMetadata = map[int64]map[string]interface{}{}
+		fastenJSON.Cha[namespace] = typeValue
+	}
+	typeValue.MethodMetadata[id] = merged
+}
+
+// Adds dependencies of the depset which are not yet present. Inner lists are merged by position.
+func (fastenJSON *JSON) mergeDepset(depset [][]Dependency) {
+	for i, inner := range depset {
+		if i == len(fastenJSON.Depset) {
+			fastenJSON.Depset = append(fastenJSON.Depset, []Dependency{})
+		}
+	dependencies:
+		for _, dependency := range inner {
+			for _, existing := range fastenJSON.Depset[i] {
+				if reflect.DeepEqual(existing, dependency) {
+					continue dependencies
+				}
+			}
+			fastenJSON.Depset[i] = append(fastenJSON.Depset[i], dependency)
+		}
+	}
+}
+
+// Merges metadata of a call into the metadata of the same call in another graph.
+// The larger number of "edges" is kept and dynamic "dispatch" wins, other entries
+// are kept if already present.
+func mergeCallMetadata(metadata map[string]interface{}, value interface{}) {
+	for key, entry := range toMetadata(value) {
+		existing, exists := metadata[key]
+		switch {
+		case !exists:
+			metadata[key] = entry
+		case key == "edges":
+			edges, _ := toId(existing)
+			other, ok := toId(entry)
+			if ok && other > edges {
+				metadata[key] = other
+			}
+		case key == "dispatch" && entry == "dynamic":
+			metadata[key] = entry
+		}
+	}
+}
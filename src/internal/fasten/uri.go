@@ -0,0 +1,130 @@
+package fasten
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// URI of a type or a method in Fasten format //forge!product$version/namespace/entity(arguments).
+// The part before the namespace is omitted for URIs local to a product. Forge and version qualify
+// the product and require it. Namespace, entity and arguments are stored unescaped. Arguments are
+// nil for types and empty for methods without arguments.
+type URI struct {
+	Forge     string
+	Product   string
+	Version   string
+	Namespace string
+	Entity    string
+	Arguments []string
+}
+
+// Formats the URI escaping its namespace, entity and arguments. Without a product the URI is
+// local, so forge and version are not written either.
+func (uri URI) String() string {
+	var builder strings.Builder
+	if uri.Product != "" {
+		builder.WriteString("//")
+		if uri.Forge != "" {
+			builder.WriteString(uri.Forge + "!")
+		}
+		builder.WriteString(uri.Product)
+		if uri.Version != "" {
+			builder.WriteString("$" + uri.Version)
+		}
+	}
+	builder.WriteString("/" + EscapeSegment(uri.Namespace) + "/" + EscapeSegment(uri.Entity))
+	if uri.Arguments != nil {
+		arguments := make([]string, len(uri.Arguments))
+		for i, argument := range uri.Arguments {
+			arguments[i] = EscapeSegment(argument)
+		}
+		builder.WriteString("(" + strings.Join(arguments, ",") + ")")
+	}
+	return builder.String()
+}
+
+// Parses a URI in Fasten format. Parsing the result of URI.String returns the original URI
+// unless it has a forge or a version but no product.
+func ParseURI(rawURI string) (URI, error) {
+	var uri URI
+	path := rawURI
+	if strings.HasPrefix(rawURI, "//") {
+		end := strings.Index(rawURI[2:], "/")
+		if end < 0 {
+			return uri, errors.New("missing namespace in URI " + rawURI)
+		}
+		authority := rawURI[2 : end+2]
+		path = rawURI[end+2:]
+		if index := strings.Index(authority, "!"); index >= 0 {
+			uri.Forge, authority = authority[:index], authority[index+1:]
+		}
+		if index := strings.Index(authority, "$"); index >= 0 {
+			uri.Product, uri.Version = authority[:index], authority[index+1:]
+		} else {
+			uri.Product = authority
+		}
+		if uri.Product == "" {
+			return uri, errors.New("missing product in URI " + rawURI)
+		}
+	}
+
+	elements := strings.Split(path, "/")
+	if len(elements) != 3 || elements[0] != "" || elements[1] == "" || elements[2] == "" {
+		return uri, errors.New("URI " + rawURI + " is not in format /namespace/entity")
+	}
+	entity := elements[2]
+	if index := strings.Index(entity, "("); index >= 0 {
+		if !strings.HasSuffix(entity, ")") {
+			return uri, errors.New("unterminated arguments in URI " + rawURI)
+		}
+		uri.Arguments = []string{}
+		if arguments := entity[index+1 : len(entity)-1]; arguments != "" {
+			for _, argument := range strings.Split(arguments, ",") {
+				unescaped, err := UnescapeSegment(argument)
+				if err != nil {
+					return uri, err
+				}
+				uri.Arguments = append(uri.Arguments, unescaped)
+			}
+		}
+		entity = entity[:index]
+	}
+
+	var err error
+	if uri.Namespace, err = UnescapeSegment(elements[1]); err != nil {
+		return uri, err
+	}
+	if uri.Entity, err = UnescapeSegment(entity); err != nil {
+		return uri, err
+	}
+	return uri, nil
+}
+
+// Returns the URI of the type declaring the method, i.e. the URI without arguments
+// and without the last element of the entity.
+func (uri URI) Type() URI {
+	typeURI := uri
+	typeURI.Arguments = nil
+	if index := strings.LastIndex(uri.Entity, "."); index >= 0 {
+		typeURI.Entity = uri.Entity[:index]
+	}
+	return typeURI
+}
+
+// Checks if the URI has a product, i.e. points to an entity of another package.
+func (uri URI) IsExternal() bool {
+	return uri.Product != ""
+}
+
+// Escapes an element of a URI. In addition to url.PathEscape : and & are escaped.
+func EscapeSegment(segment string) string {
+	segment = url.PathEscape(segment)
+	segment = strings.ReplaceAll(segment, ":", "%3A")
+	return strings.ReplaceAll(segment, "&", "%26")
+}
+
+// Reverts EscapeSegment.
+func UnescapeSegment(segment string) (string, error) {
+	return url.PathUnescape(segment)
+}
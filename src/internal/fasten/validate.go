@@ -0,0 +1,267 @@
+package fasten
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severities of validation findings. Only errors make a call graph invalid.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Codes of validation findings.
+const (
+	CodeMissingCoordinate = "missing-coordinate"
+	CodeInvalidNamespace  = "invalid-namespace"
+	CodeInvalidURI        = "invalid-uri"
+	CodeUnknownURI        = "unknown-uri"
+	CodeDuplicateID       = "duplicate-id"
+	CodeDuplicateMethod   = "duplicate-method"
+	CodeUnknownID         = "unknown-id"
+	CodeInvalidEdge       = "invalid-edge"
+	CodeDuplicateEdge     = "duplicate-edge"
+	CodeInvalidDepset     = "invalid-depset"
+	CodeInvalidTimestamp  = "invalid-timestamp"
+	CodeInvalidJSON       = "invalid-json"
+)
+
+// Unix timestamp of the launch of crates.io. No crate can be published earlier.
+const cratesioLaunch = 1415664000
+
+var constraintRegex = regexp.MustCompile(`^(\*|[\[(][^,\[\]()]*(,[^,\[\]()]*)?[\])])$`)
+
+// Finding of the validation of a Fasten call graph. Location points to the
+// offending element, e.g. graph.internalCalls[3].
+type Finding struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Location string `json:"location"`
+}
+
+// Checks if any of the findings is an error.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validates the Fasten call graph. Checks that the coordinate is set, ids of calls
+// are defined in the CHA, URIs are well-formed, the depset has the expected shape,
+// calls are not duplicated and the timestamp is sane.
+func Validate(fastenJSON *JSON) []Finding {
+	findings := []Finding{}
+	report := func(severity string, code string, location string, message string) {
+		findings = append(findings, Finding{Severity: severity, Code: code, Message: message, Location: location})
+	}
+
+	for _, field := range [][2]string{{"product", fastenJSON.Product}, {"forge", fastenJSON.Forge}, {"version", fastenJSON.Version}} {
+		if field[1] == "" {
+			report(SeverityError, CodeMissingCoordinate, field[0], "missing "+field[0])
+		}
+	}
+
+	methods := validateCHA(fastenJSON, report)
+	validateInternalCalls(fastenJSON, methods, report)
+	validateExternalCalls(fastenJSON, methods, report)
+	validateDepset(fastenJSON, report)
+
+	if fastenJSON.Timestamp != -1 &&
+		(fastenJSON.Timestamp < cratesioLaunch || fastenJSON.Timestamp > time.Now().Add(24*time.Hour).Unix()) {
+		report(SeverityError, CodeInvalidTimestamp, "timestamp",
+			"timestamp "+strconv.FormatInt(fastenJSON.Timestamp, 10)+" is neither -1 nor between the launch of crates.io and now")
+	}
+
+	return findings
+}
+
+// Validates namespaces and methods of the CHA. Returns the ids of all methods.
+func validateCHA(fastenJSON *JSON, report func(string, string, string, string)) map[int64]string {
+	methods := make(map[int64]string)
+	uris := make(map[string]int64)
+	for _, namespace := range sortedNamespaces(fastenJSON.Cha) {
+		location := "cha." + namespace
+		if uri, err := ParseURI(namespace); err != nil || uri.IsExternal() || uri.Arguments != nil {
+			report(SeverityError, CodeInvalidNamespace, location, "namespace is not in format /module/Type")
+		}
+		if strings.Contains(namespace, "UNKNOWN") {
+			report(SeverityError, CodeUnknownURI, location, "namespace contains UNKNOWN")
+		}
+		for _, supers := range [][]string{fastenJSON.Cha[namespace].SuperInterfaces, fastenJSON.Cha[namespace].SuperClasses} {
+			for _, super := range supers {
+				if strings.Contains(super, "UNKNOWN") {
+					report(SeverityError, CodeUnknownURI, location, "super type "+super+" contains UNKNOWN")
+				}
+			}
+		}
+
+		for _, id := range sortedIds(fastenJSON.Cha[namespace].Methods) {
+			uri := fastenJSON.Cha[namespace].Methods[id]
+			methodLocation := location + ".methods." + strconv.FormatInt(id, 10)
+			if other, exists := methods[id]; exists {
+				report(SeverityError, CodeDuplicateID, methodLocation, "id is also used by "+other)
+			}
+			methods[id] = uri
+			if other, exists := uris[uri]; exists {
+				report(SeverityWarning, CodeDuplicateMethod, methodLocation, uri+" is also defined with id "+strconv.FormatInt(other, 10))
+			} else {
+				uris[uri] = id
+			}
+			if parsed, err := ParseURI(uri); err != nil || parsed.Arguments == nil || parsed.Type().String() != namespace {
+				report(SeverityError, CodeInvalidURI, methodLocation, uri+" is not in format "+namespace+".method(arguments)")
+			}
+			if strings.Contains(uri, "UNKNOWN") {
+				report(SeverityError, CodeUnknownURI, methodLocation, uri+" contains UNKNOWN")
+			}
+		}
+	}
+	return methods
+}
+
+// Validates that internal calls are triples of known ids and metadata which are not duplicated.
+func validateInternalCalls(fastenJSON *JSON, methods map[int64]string, report func(string, string, string, string)) {
+	calls := make(map[[2]int64]struct{})
+	for i, call := range fastenJSON.Graph.InternalCalls {
+		location := "graph.internalCalls[" + strconv.Itoa(i) + "]"
+		if len(call) != 3 {
+			report(SeverityError, CodeInvalidEdge, location, "internal call is not a triple of source, target and metadata")
+			continue
+		}
+		source, sourceOk := toId(call[0])
+		target, targetOk := toId(call[1])
+		if !sourceOk || !targetOk {
+			report(SeverityError, CodeInvalidEdge, location, "source or target of the internal call is not an id")
+			continue
+		}
+		if !isMetadata(call[2]) {
+			report(SeverityError, CodeInvalidEdge, location, "metadata of the internal call is not an object")
+		}
+		for _, id := range []int64{source, target} {
+			if _, exists := methods[id]; !exists {
+				report(SeverityError, CodeUnknownID, location, "id "+strconv.FormatInt(id, 10)+" is not defined in the CHA")
+			}
+		}
+		key := [2]int64{source, target}
+		if _, exists := calls[key]; exists {
+			report(SeverityWarning, CodeDuplicateEdge, location, "internal call is duplicated")
+		}
+		calls[key] = struct{}{}
+	}
+}
+
+// Validates that external calls start at known ids, point to well-formed URIs
+// and are not duplicated.
+func validateExternalCalls(fastenJSON *JSON, methods map[int64]string, report func(string, string, string, string)) {
+	calls := make(map[string]struct{})
+	for i, call := range fastenJSON.Graph.ExternalCalls {
+		location := "graph.externalCalls[" + strconv.Itoa(i) + "]"
+		if len(call) != 3 {
+			report(SeverityError, CodeInvalidEdge, location, "external call is not a triple of source, target and metadata")
+			continue
+		}
+		source, sourceOk := call[0].(string)
+		target, targetOk := call[1].(string)
+		if !sourceOk || !targetOk {
+			report(SeverityError, CodeInvalidEdge, location, "source or target of the external call is not a string")
+			continue
+		}
+		if !isMetadata(call[2]) {
+			report(SeverityError, CodeInvalidEdge, location, "metadata of the external call is not an object")
+		}
+
+		if id, err := strconv.ParseInt(source, 10, 64); err != nil {
+			report(SeverityError, CodeInvalidEdge, location, "source "+source+" is not an id")
+		} else if _, exists := methods[id]; !exists {
+			report(SeverityError, CodeUnknownID, location, "id "+source+" is not defined in the CHA")
+		}
+		if uri, err := ParseURI(target); err != nil || uri.Forge == "" || uri.Version == "" || uri.Arguments == nil {
+			report(SeverityError, CodeInvalidURI, location, target+" is not in format //forge!product$version/module/Type.method(arguments)")
+		}
+		if strings.Contains(target, "UNKNOWN") {
+			report(SeverityError, CodeUnknownURI, location, target+" contains UNKNOWN")
+		}
+
+		key := source + " " + target
+		if _, exists := calls[key]; exists {
+			report(SeverityWarning, CodeDuplicateEdge, location, "external call is duplicated")
+		}
+		calls[key] = struct{}{}
+	}
+}
+
+// Validates that dependencies of the depset have a product, a forge and interval constraints.
+func validateDepset(fastenJSON *JSON, report func(string, string, string, string)) {
+	if fastenJSON.Depset == nil {
+		report(SeverityWarning, CodeInvalidDepset, "depset", "depset is null instead of an array")
+	}
+	for i, inner := range fastenJSON.Depset {
+		for j, dependency := range inner {
+			location := "depset[" + strconv.Itoa(i) + "][" + strconv.Itoa(j) + "]"
+			if dependency.Product == "" || dependency.Forge == "" {
+				report(SeverityError, CodeInvalidDepset, location, "dependency is missing product or forge")
+			}
+			if len(dependency.Constraints) == 0 {
+				report(SeverityError, CodeInvalidDepset, location, "dependency "+dependency.Product+" has no constraints")
+			}
+			for _, constraint := range dependency.Constraints {
+				if !constraintRegex.MatchString(constraint) {
+					report(SeverityError, CodeInvalidDepset, location, "constraint "+constraint+" of "+dependency.Product+" is not in interval notation")
+				}
+			}
+		}
+	}
+}
+
+// Converts an id of a call created in memory or read from JSON to int64.
+func toId(value interface{}) (int64, bool) {
+	switch value := value.(type) {
+	case int64:
+		return value, true
+	case int:
+		return int64(value), true
+	case float64:
+		return int64(value), value == float64(int64(value))
+	case json.Number:
+		id, err := value.Int64()
+		return id, err == nil
+	}
+	return 0, false
+}
+
+// Checks if the value is a metadata object of a call.
+func isMetadata(value interface{}) bool {
+	switch value.(type) {
+	case map[string]string, map[string]interface{}, nil:
+		return true
+	}
+	return false
+}
+
+// Returns namespaces of the CHA in sorted order.
+func sortedNamespaces(cha map[string]Type) []string {
+	namespaces := make([]string, 0, len(cha))
+	for namespace := range cha {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Returns ids of the methods in ascending order.
+func sortedIds(methods map[int64]string) []int64 {
+	ids := make([]int64, 0, len(methods))
+	for id := range methods {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -2,11 +2,11 @@ package rust
 
 import (
 	"RustCallGraphConverter/src/internal/fasten"
-	"encoding/json"
-	"io/ioutil"
-	"net/http"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
-	"time"
 )
 
 // CallGraph
@@ -27,157 +27,540 @@ type Node struct {
 	SourceLocation    string `json:"source_location"`
 }
 
-type CratesioAPI struct {
-	Version CratesioVersion `json:"version"`
+// Kinds of nodes of a call graph.
+const (
+	kindFunction = "function"
+	kindMacro    = "macro"
+)
+
+// Method of a Fasten graph created for a node of the rust call graph. Nodes with generic
+// types are converted to a method for each instantiation of their generic types.
+type fastenMethod struct {
+	id            int64
+	uri           fasten.URI
+	instantiation []string
+}
+
+// Matches the lines of a source location in format file:line:column: line:column.
+var sourceLinesPattern = regexp.MustCompile(`:(\d+):\d+: (\d+):\d+$`)
+
+// Identity of a crate in a call graph. A build graph can contain several
+// versions of one crate.
+type crate struct {
+	Name    string
+	Version string
+}
+
+// Returns the crate the node belongs to.
+func (node Node) crate() crate {
+	if node.PackageVersion == "" {
+		return crate{Name: node.CrateName, Version: "0.0.0"}
+	}
+	return crate{Name: node.CrateName, Version: node.PackageVersion}
 }
 
-type CratesioVersion struct {
-	CreatedAt string `json:"created_at"`
+// Returns metadata of the method of the node: first and last line, number of lines,
+// access and whether it is a function or a macro.
+func (node Node) metadata(kind string) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"numLines": node.NumberOfLines,
+		"access":   "private",
+		"kind":     kind,
+	}
+	if node.ExternallyVisible {
+		metadata["access"] = "public"
+	}
+	if lines := sourceLinesPattern.FindStringSubmatch(node.SourceLocation); lines != nil {
+		first, _ := strconv.ParseInt(lines[1], 10, 64)
+		last, _ := strconv.ParseInt(lines[2], 10, 64)
+		metadata["first"] = first
+		metadata["last"] = last
+	}
+	return metadata
+}
+
+// Provides release timestamps of packages.
+type TimestampProvider interface {
+	// Returns the unix timestamp of the release of the given version of a product.
+	Timestamp(product string, version string) (int64, error)
+}
+
+// Options of the conversion.
+type Options struct {
+	// Provider of the timestamps of the converted packages.
+	// Timestamps are not resolved when nil.
+	Timestamps TimestampProvider
+	// Emit graphs of all crates with a known version found in the call graph,
+	// not only the crate of the converted package.
+	AllCrates bool
+	// Attribute closures and their calls to the function they are defined in
+	// instead of converting them to methods of their own.
+	CollapseClosures bool
+	// Add a call to the method of every impl of the trait for dynamic calls of a trait
+	// method, found in the type hierarchies of the package and the standard library.
+	ExpandDynamicDispatch bool
 }
 
-//Converts rustJSON to FastenJSON.
-func (rustJSON JSON) ConvertToFastenJson(rawTypeHierarchy TypeHierarchy, stdTypeHierarchy MapTypeHierarchy, pkg string) (fasten.JSON, error) {
-	var jsons = make(map[string]*fasten.JSON)
-	var methods = make(map[int64]string)
-	var edgeMap = make(map[int64][]int64)
+//Converts rustJSON to FastenJSON. Returns the graph of the crate of the package
+// followed by the graphs of the other crates sorted by name if options.AllCrates is set.
+func (rustJSON JSON) ConvertToFastenJson(rawTypeHierarchy TypeHierarchy, stdTypeHierarchy MapTypeHierarchy, pkg string, options Options) ([]fasten.JSON, error) {
+	var jsons = make(map[crate]*fasten.JSON)
+	var methods = make(map[int64]crate)
+	var nodes = make(map[int64]Node)
+	var edgeMap = make(map[int64][]fastenMethod)
+	var versioned = make(map[crate]bool)
 
 	typeHierarchy := rawTypeHierarchy.ConvertToMap()
+	typeHierarchy.collapseClosures = options.CollapseClosures
+	stdTypeHierarchy.collapseClosures = options.CollapseClosures
+	typeHierarchy.std = &stdTypeHierarchy
 
-	for _, node := range append(rustJSON.Functions, rustJSON.Macros...) {
-		if _, ok := jsons[node.CrateName]; !ok {
-			var version string
-			if node.PackageVersion == "" {
-				version = "0.0.0"
-			} else {
-				version = node.PackageVersion
-			}
-			jsons[node.CrateName] = &fasten.JSON{
-				Product:   node.CrateName,
+	for i, node := range append(rustJSON.Functions, rustJSON.Macros...) {
+		nodeCrate := node.crate()
+		kind := kindFunction
+		if i >= len(rustJSON.Functions) {
+			kind = kindMacro
+		}
+		if _, ok := jsons[nodeCrate]; !ok {
+			jsons[nodeCrate] = &fasten.JSON{
+				Product:   nodeCrate.Name,
 				Forge:     "cratesio",
 				Generator: "rust-callgraphs",
 				Depset:    [][]fasten.Dependency{},
-				Version:   version,
+				Version:   nodeCrate.Version,
 				Cha:       map[string]fasten.Type{},
 				Graph: fasten.CallGraph{
-					InternalCalls: make([][]int64, 0),
+					InternalCalls: make([][]interface{}, 0),
 					ExternalCalls: make([][]interface{}, 0),
 				},
 				Timestamp:             -1,
 				DuplicateCHA:          make(map[string]int64),
-				DuplicateInternalCall: make(map[int64]map[int64]struct{}),
-				DuplicateExternalCall: make(map[int64]map[string]struct{}),
+				DuplicateInternalCall: make(map[int64]map[int64]int),
+				DuplicateExternalCall: make(map[int64]map[string]int),
 			}
 		}
-		id := addMethodToCHA(jsons, node, typeHierarchy)
+		id, err := addMethodToCHA(jsons, node, node.metadata(kind), typeHierarchy)
+		if err != nil {
+			return nil, err
+		}
 		edgeMap[node.Id] = id
-		methods[node.Id] = node.CrateName
+		methods[node.Id] = nodeCrate
+		nodes[node.Id] = node
+		if node.PackageVersion != "" {
+			versioned[nodeCrate] = true
+		}
+	}
+
+	functions := rustJSON.functionsByDefPath()
+	if !options.CollapseClosures {
+		if err := rustJSON.addDefinedIn(jsons, edgeMap, typeHierarchy, functions); err != nil {
+			return nil, err
+		}
 	}
 
 	for _, edge := range rustJSON.FunctionCalls {
 		rustJSON.addCallToGraph(jsons, methods, edge, typeHierarchy, stdTypeHierarchy, edgeMap)
 	}
+	if options.ExpandDynamicDispatch {
+		for _, edge := range rustJSON.FunctionCalls {
+			if edge[2] != true {
+				rustJSON.addDispatchCalls(jsons, methods, nodes, edge, typeHierarchy, stdTypeHierarchy, edgeMap, functions)
+			}
+		}
+	}
+
+	defPathCrates := rustJSON.defPathCrates()
+	var crates []crate
+	for jsonCrate := range jsons {
+		crates = append(crates, jsonCrate)
+		addTraitHierarchyToCHA(jsons[jsonCrate], defPathCrates[jsonCrate], typeHierarchy)
+	}
+	sort.Slice(crates, func(i, j int) bool {
+		if crates[i].Name != crates[j].Name {
+			return crates[i].Name < crates[j].Name
+		}
+		return crates[i].Version < crates[j].Version
+	})
+
+	pkgCrate := getPackageCrate(pkg, crates)
+	var results []fasten.JSON
+	if result := jsons[pkgCrate]; result != nil {
+		resolveTimestamp(result, options.Timestamps)
+		results = append(results, *result)
+	}
+
+	if options.AllCrates {
+		for _, jsonCrate := range crates {
+			if jsonCrate != pkgCrate && versioned[jsonCrate] {
+				resolveTimestamp(jsons[jsonCrate], options.Timestamps)
+				results = append(results, *jsons[jsonCrate])
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// Finds the crate of a package in format /packageName/packageVersion/ among the sorted
+// crates of the call graph. Falls back to the first crate with the name of the package
+// if no crate has its version.
+func getPackageCrate(pkg string, crates []crate) crate {
+	elements := strings.Split(pkg, "/")
+	pkgCrate := crate{Name: strings.ReplaceAll(elements[1], "-", "_")}
+	if len(elements) > 2 {
+		pkgCrate.Version = elements[2]
+	}
+
+	for _, candidate := range crates {
+		if candidate == pkgCrate {
+			return candidate
+		}
+	}
+	for _, candidate := range crates {
+		if candidate.Name == pkgCrate.Name {
+			return candidate
+		}
+	}
+	return pkgCrate
+}
+
+// Returns the ids of the nodes of each crate keyed by the canonical key of their def-path.
+func (rustJSON JSON) functionsByDefPath() map[crate]map[string]int64 {
+	functions := make(map[crate]map[string]int64)
+	for _, node := range append(append([]Node{}, rustJSON.Functions...), rustJSON.Macros...) {
+		if _, exists := functions[node.crate()]; !exists {
+			functions[node.crate()] = make(map[string]int64)
+		}
+		functions[node.crate()][defPathKey(node.RelativeDefId)] = node.Id
+	}
+	return functions
+}
+
+// Returns the crates in the def-paths of the nodes of each crate. Def-path crates have
+// the name of the crate in the def-path and the version of the node.
+func (rustJSON JSON) defPathCrates() map[crate]map[crate]bool {
+	crates := make(map[crate]map[crate]bool)
+	for _, node := range append(append([]Node{}, rustJSON.Functions...), rustJSON.Macros...) {
+		nodeCrate := node.crate()
+		if _, exists := crates[nodeCrate]; !exists {
+			crates[nodeCrate] = make(map[crate]bool)
+		}
+		if path, err := parseDefPath(node.RelativeDefId); err == nil {
+			crates[nodeCrate][crate{Name: path.crate.name, Version: nodeCrate.Version}] = true
+		}
+	}
+	return crates
+}
+
+// Adds the trait hierarchy of the crate to the CHA: all traits implemented by its types,
+// the traits of its trait object types and their supertraits as super classes, and the
+// supertraits of its traits. Only items of the def-path crates of the crate are added.
+// Types are only added if they have methods in the CHA, except for trait object types.
+// Trait object types without impl blocks are added to the module of their first trait.
+// Supertraits are added to the type URI of the trait, e.g. /a/Shape, referenced by the
+// types implementing it, not to the namespace of its methods, /a.Shape/NO-TYPE-DEFINITION.
+func addTraitHierarchyToCHA(fastenJSON *fasten.JSON, defPathCrates map[crate]bool, typeHierarchy MapTypeHierarchy) {
+	impls := make([]Impl, 0, len(typeHierarchy.Impls))
+	for _, impl := range typeHierarchy.Impls {
+		impls = append(impls, impl)
+	}
+	sort.Slice(impls, func(i, j int) bool { return impls[i].Id < impls[j].Id })
+	implemented := make(map[int64]bool)
+	for _, impl := range impls {
+		path, err := parseDefPath(impl.RelativeDefId)
+		if err != nil {
+			continue
+		}
+		implCrate := typeHierarchy.crateOf(path.crate.name, impl.PackageVersion)
+		if !defPathCrates[implCrate] {
+			continue
+		}
+		implemented[impl.TypeId] = true
+		traitObject := len(typeHierarchy.Types[impl.TypeId].TraitIds) > 0
+		for _, namespace := range typeHierarchy.getImplNamespaces(impl.RelativeDefId, impl.PackageVersion) {
+			if _, exists := fastenJSON.Cha[namespace]; !exists && !traitObject {
+				continue
+			}
+			if trait, ok := typeHierarchy.Traits[impl.TraitId]; ok && impl.TraitId != 0 {
+				fastenJSON.AddInterfaceToCHA(namespace, typeHierarchy.getTraitURI(trait, implCrate))
+			}
+			addTraitObjectToCHA(fastenJSON, namespace, typeHierarchy.Types[impl.TypeId], implCrate, typeHierarchy)
+		}
+	}
 
-	pkgCrate := strings.Split(pkg, "/")[1]
-	pkgCrate = strings.ReplaceAll(pkgCrate, "-", "_")
-	result := jsons[pkgCrate]
-	if result != nil {
-		resolveTimestamp(result)
-		return *result, nil
+	types := make([]Type, 0, len(typeHierarchy.Types))
+	for _, typeInstance := range typeHierarchy.Types {
+		if len(typeInstance.TraitIds) > 0 && !implemented[typeInstance.Id] {
+			types = append(types, typeInstance)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Id < types[j].Id })
+	for _, typeInstance := range types {
+		trait, ok := typeHierarchy.Traits[typeInstance.TraitIds[0]]
+		if !ok {
+			continue
+		}
+		path, err := parseDefPath(trait.RelativeDefId)
+		if err != nil {
+			continue
+		}
+		traitCrate := typeHierarchy.crateOf(path.crate.name, trait.PackageVersion)
+		if !defPathCrates[traitCrate] {
+			continue
+		}
+		uri, err := fasten.ParseURI(typeHierarchy.getTraitPath(trait.RelativeDefId, trait.PackageVersion))
+		if err != nil {
+			continue
+		}
+		uri.Entity = typeInstance.StringId
+		addTraitObjectToCHA(fastenJSON, uri.String(), typeInstance, traitCrate, typeHierarchy)
+	}
+
+	traits := make([]Trait, 0, len(typeHierarchy.Traits))
+	for _, trait := range typeHierarchy.Traits {
+		traits = append(traits, trait)
 	}
+	sort.Slice(traits, func(i, j int) bool { return traits[i].Id < traits[j].Id })
+	for _, trait := range traits {
+		path, err := parseDefPath(trait.RelativeDefId)
+		if err != nil {
+			continue
+		}
+		traitCrate := typeHierarchy.crateOf(path.crate.name, trait.PackageVersion)
+		if !defPathCrates[traitCrate] {
+			continue
+		}
+		for _, supertraitId := range trait.SupertraitIds {
+			if supertrait, ok := typeHierarchy.Traits[supertraitId]; ok {
+				fastenJSON.AddInterfaceToCHA(typeHierarchy.getTraitPath(trait.RelativeDefId, trait.PackageVersion), typeHierarchy.getTraitURI(supertrait, traitCrate))
+			}
+		}
+	}
+}
 
-	return fasten.JSON{}, nil
+// Adds the traits of a trait object type and all their supertraits as super classes
+// of the namespace of the type. Other types are left unchanged.
+func addTraitObjectToCHA(fastenJSON *fasten.JSON, namespace string, typeInstance Type, graphCrate crate, typeHierarchy MapTypeHierarchy) {
+	for _, trait := range typeHierarchy.getSupertraits(typeInstance.TraitIds) {
+		fastenJSON.AddSuperClassToCHA(namespace, typeHierarchy.getTraitURI(trait, graphCrate))
+	}
+}
+
+// Adds the URI of the function each closure is defined in to the metadata of the closure
+// as "definedIn". Functions missing in the call graph are added to the CHA without metadata.
+func (rustJSON JSON) addDefinedIn(jsons map[crate]*fasten.JSON, edgeMap map[int64][]fastenMethod,
+	typeHierarchy MapTypeHierarchy, functions map[crate]map[string]int64) error {
+	for _, node := range append(append([]Node{}, rustJSON.Functions...), rustJSON.Macros...) {
+		parentDefId, isClosure := closureParent(node.RelativeDefId)
+		if !isClosure {
+			continue
+		}
+		var parents []fastenMethod
+		if parentId, exists := functions[node.crate()][defPathKey(parentDefId)]; exists {
+			parents = edgeMap[parentId]
+		} else {
+			parent := node
+			parent.RelativeDefId = parentDefId
+			var err error
+			if parents, err = addMethodToCHA(jsons, parent, nil, typeHierarchy); err != nil {
+				return err
+			}
+		}
+
+		fastenJSON := jsons[node.crate()]
+		for _, closure := range edgeMap[node.Id] {
+			for _, parent := range parents {
+				if strings.Join(closure.instantiation, ", ") == strings.Join(parent.instantiation, ", ") {
+					fastenJSON.SetMethodMetadata(getNamespace(closure.uri), closure.id, "definedIn", parent.uri.String())
+				}
+			}
+		}
+	}
+	return nil
 }
 
 // Add a call to graph of a source package.
-func (rustJSON JSON) addCallToGraph(jsons map[string]*fasten.JSON, methods map[int64]string,
-	edge []interface{}, typeHierarchy MapTypeHierarchy, stdTypeHierarchy MapTypeHierarchy, edgeMap map[int64][]int64) {
+func (rustJSON JSON) addCallToGraph(jsons map[crate]*fasten.JSON, methods map[int64]crate,
+	edge []interface{}, typeHierarchy MapTypeHierarchy, stdTypeHierarchy MapTypeHierarchy, edgeMap map[int64][]fastenMethod) {
 	sourceIndex := int64(edge[0].(float64))
 	targetIndex := int64(edge[1].(float64))
 	sourcePkg := methods[sourceIndex]
 	targetPkg := methods[targetIndex]
 	source := jsons[sourcePkg]
 	target := jsons[targetPkg]
+	dispatch := "dynamic"
+	if edge[2] == true {
+		dispatch = "static"
+	}
 
 	if targetPkg != sourcePkg {
 		source.AddDependency(target)
 
 		for _, sourceMethod := range edgeMap[sourceIndex] {
-			for _, targetMethod := range rustJSON.getTargetMethod(typeHierarchy, stdTypeHierarchy, targetIndex) {
-				var metadata = make(map[string]string)
-				if edge[2] == true {
-					metadata["dispatch"] = "static"
-				} else {
-					metadata["dispatch"] = "dynamic"
-				}
-				source.AddExternalCall(sourceMethod, "//"+target.Forge+"!"+target.Product+"$"+target.Version+targetMethod, metadata)
+			for _, targetMethod := range rustJSON.getTargetMethod(typeHierarchy, stdTypeHierarchy, rustJSON.Functions[targetIndex]) {
+				targetMethod.uri.Forge, targetMethod.uri.Product, targetMethod.uri.Version = target.Forge, target.Product, target.Version
+				metadata := source.AddExternalCall(sourceMethod.id, targetMethod.uri.String())
+				addCallMetadata(metadata, dispatch, sourceMethod.instantiation, targetMethod.types)
 			}
 		}
 	} else {
 		for _, sourceMethod := range edgeMap[sourceIndex] {
 			for _, targetMethod := range edgeMap[targetIndex] {
-				source.AddInternalCall(sourceMethod, targetMethod)
+				metadata := source.AddInternalCall(sourceMethod.id, targetMethod.id)
+				addCallMetadata(metadata, dispatch, sourceMethod.instantiation, targetMethod.instantiation)
 			}
 		}
 	}
 }
 
+// Adds calls resolved by class hierarchy analysis for a dynamic edge of the rust call graph:
+// a call to the method of every impl of the called trait method. Impls without the method
+// in the call graph call the method of the trait instead. Methods missing in the call graph
+// are not called, nor are edges whose source or target is not a node of the call graph.
+func (rustJSON JSON) addDispatchCalls(jsons map[crate]*fasten.JSON, methods map[int64]crate, nodes map[int64]Node, edge []interface{},
+	typeHierarchy MapTypeHierarchy, stdTypeHierarchy MapTypeHierarchy, edgeMap map[int64][]fastenMethod, functions map[crate]map[string]int64) {
+	sourceIndex := int64(edge[0].(float64))
+	targetIndex := int64(edge[1].(float64))
+	target, exists := nodes[targetIndex]
+	if _, sourceExists := nodes[sourceIndex]; !exists || !sourceExists {
+		return
+	}
+	sourcePkg := methods[sourceIndex]
+	source := jsons[sourcePkg]
+
+	implementations, traitMethod := findImplementingMethods(target.RelativeDefId, target.PackageVersion, typeHierarchy, stdTypeHierarchy)
+	called := make(map[int64]bool)
+	for _, implementing := range implementations {
+		nodeId, exists := implementing.nodeId(functions)
+		if !exists {
+			if nodeId, exists = traitMethod.nodeId(functions); !exists {
+				continue
+			}
+		}
+		if called[nodeId] {
+			continue
+		}
+		called[nodeId] = true
+
+		if methods[nodeId] == sourcePkg {
+			for _, sourceMethod := range edgeMap[sourceIndex] {
+				for _, targetMethod := range edgeMap[nodeId] {
+					metadata := source.AddInternalCall(sourceMethod.id, targetMethod.id)
+					addResolvedCallMetadata(metadata, sourceMethod.instantiation, targetMethod.instantiation)
+				}
+			}
+		} else {
+			targetJSON := jsons[methods[nodeId]]
+			for _, sourceMethod := range edgeMap[sourceIndex] {
+				for _, targetMethod := range rustJSON.getTargetMethod(typeHierarchy, stdTypeHierarchy, nodes[nodeId]) {
+					targetMethod.uri.Forge, targetMethod.uri.Product, targetMethod.uri.Version = targetJSON.Forge, targetJSON.Product, targetJSON.Version
+					metadata := source.AddExternalCall(sourceMethod.id, targetMethod.uri.String())
+					addResolvedCallMetadata(metadata, sourceMethod.instantiation, targetMethod.types)
+				}
+			}
+		}
+	}
+}
+
+// Adds a call resolved by class hierarchy analysis to the metadata of a Fasten call and marks
+// it with "resolution": "cha". Calls which are also edges of the rust call graph are left unchanged.
+func addResolvedCallMetadata(metadata map[string]interface{}, sourceTypes []string, targetTypes []string) {
+	if len(metadata) > 0 && metadata["resolution"] != "cha" {
+		return
+	}
+	metadata["resolution"] = "cha"
+	addCallMetadata(metadata, "dynamic", sourceTypes, targetTypes)
+}
+
+// Adds a rust edge to the metadata of a Fasten call. Counts the rust edges collapsed
+// into the call in "edges". Dynamic dispatch of any of them makes the call dynamic.
+// "instantiation" records the generic types of the source and target methods.
+func addCallMetadata(metadata map[string]interface{}, dispatch string, sourceTypes []string, targetTypes []string) {
+	if edges, ok := metadata["edges"].(int64); ok {
+		metadata["edges"] = edges + 1
+	} else {
+		metadata["edges"] = int64(1)
+	}
+	if metadata["dispatch"] != "dynamic" {
+		metadata["dispatch"] = dispatch
+	}
+
+	if _, exists := metadata["instantiation"]; exists {
+		return
+	}
+	instantiation := map[string]string{}
+	if len(sourceTypes) > 0 {
+		instantiation["source"] = strings.Join(sourceTypes, ", ")
+	}
+	if len(targetTypes) > 0 {
+		instantiation["target"] = strings.Join(targetTypes, ", ")
+	}
+	if len(instantiation) > 0 {
+		metadata["instantiation"] = instantiation
+	}
+}
+
 // Resolves the full target method path from a type hierarchy of the target package
 // or from the type hierarchy of the standard library.
-func (rustJSON JSON) getTargetMethod(typeHierarchy MapTypeHierarchy, stdTypeHierarchy MapTypeHierarchy, targetIndex int64) []string {
-	if path, err := typeHierarchy.getFullPath(rustJSON.Functions[targetIndex].RelativeDefId); err == nil {
-		if typeHierarchy.isGenericType(rustJSON.Functions[targetIndex].RelativeDefId) {
-			return typeHierarchy.getGenericFullPaths(path)
+func (rustJSON JSON) getTargetMethod(typeHierarchy MapTypeHierarchy, stdTypeHierarchy MapTypeHierarchy, target Node) []genericInstance {
+	if path, err := typeHierarchy.getFullPath(target.RelativeDefId, target.PackageVersion); err == nil {
+		if typeHierarchy.isGenericType(target.RelativeDefId, target.PackageVersion) {
+			return typeHierarchy.getGenericInstances(path)
 		}
-		return []string{path}
+		return []genericInstance{{uri: path}}
 	}
-	if path, err := stdTypeHierarchy.getFullPath(rustJSON.Functions[targetIndex].RelativeDefId); err == nil {
-		if typeHierarchy.isGenericType(rustJSON.Functions[targetIndex].RelativeDefId) {
-			return typeHierarchy.getGenericFullPaths(path)
+	if path, err := stdTypeHierarchy.getFullPath(target.RelativeDefId, target.PackageVersion); err == nil {
+		if typeHierarchy.isGenericType(target.RelativeDefId, target.PackageVersion) {
+			return typeHierarchy.getGenericInstances(path)
 		}
-		return []string{path}
+		return []genericInstance{{uri: path}}
 	}
-	return []string{"UNKNOWN"}
+	return []genericInstance{{uri: fasten.URI{Namespace: "UNKNOWN", Entity: "UNKNOWN", Arguments: []string{}}}}
 }
 
-// Add method to Class Hierarchy or passes control to addGenericMethodToCHA
-// in case the method is has generic types.
-func addMethodToCHA(jsons map[string]*fasten.JSON, node Node, typeHierarchy MapTypeHierarchy) []int64 {
-	fastenJSON := jsons[node.CrateName]
-	path, _ := typeHierarchy.getFullPath(node.RelativeDefId)
+// Add method with its metadata to Class Hierarchy or passes control to addGenericMethodToCHA
+// in case the method is has generic types. Returns an error if the def-path
+// of the node cannot be parsed.
+func addMethodToCHA(jsons map[crate]*fasten.JSON, node Node, metadata map[string]interface{}, typeHierarchy MapTypeHierarchy) ([]fastenMethod, error) {
+	fastenJSON := jsons[node.crate()]
+	path, err := typeHierarchy.getFullPath(node.RelativeDefId, node.PackageVersion)
+	if err != nil && err != errNoType {
+		return nil, errors.New("node " + strconv.FormatInt(node.Id, 10) + ": " + err.Error())
+	}
 	namespace := getNamespace(path)
 
-	if typeHierarchy.isGenericType(node.RelativeDefId) {
-		return addGenericMethodToCHA(jsons, node, typeHierarchy)
+	if typeHierarchy.isGenericType(node.RelativeDefId, node.PackageVersion) {
+		return addGenericMethodToCHA(jsons, node, metadata, typeHierarchy), nil
 	} else {
-		id := fastenJSON.AddMethodToCHA(namespace, path)
-		fastenJSON.AddInterfaceToCHA(namespace, typeHierarchy.getTraitFromTypeHierarchy(node.RelativeDefId))
+		id := fastenJSON.AddMethodToCHA(namespace, path.String(), metadata)
+		fastenJSON.AddInterfaceToCHA(namespace, typeHierarchy.getTraitFromTypeHierarchy(node.RelativeDefId, node.PackageVersion))
 		fastenJSON.AddFilenameToCHA(namespace, getFileName(node.SourceLocation, node.CrateName+"-"+node.PackageVersion))
-		return []int64{id}
+		return []fastenMethod{{id: id, uri: path}}, nil
 	}
 }
 
 // Processes a method with generic types and adds each generic type
 // to CHA separately.
-func addGenericMethodToCHA(jsons map[string]*fasten.JSON, node Node, typeHierarchy MapTypeHierarchy) []int64 {
-	fastenJSON := jsons[node.CrateName]
-	fullPath, _ := typeHierarchy.getFullPath(node.RelativeDefId)
-	var ids []int64
+func addGenericMethodToCHA(jsons map[crate]*fasten.JSON, node Node, metadata map[string]interface{}, typeHierarchy MapTypeHierarchy) []fastenMethod {
+	fastenJSON := jsons[node.crate()]
+	fullPath, _ := typeHierarchy.getFullPath(node.RelativeDefId, node.PackageVersion)
+	var methods []fastenMethod
 
-	paths := typeHierarchy.getGenericFullPaths(fullPath)
+	instances := typeHierarchy.getGenericInstances(fullPath)
 	var namespaces []string
-	for _, path := range paths {
-		namespaces = append(namespaces, getNamespace(path))
+	for _, instance := range instances {
+		namespaces = append(namespaces, getNamespace(instance.uri))
 	}
 
-	for i := 0; i < len(paths) && i < len(namespaces); i++ {
-		id := fastenJSON.AddMethodToCHA(namespaces[i], paths[i])
-		ids = append(ids, id)
+	for i := 0; i < len(instances) && i < len(namespaces); i++ {
+		id := fastenJSON.AddMethodToCHA(namespaces[i], instances[i].uri.String(), metadata)
+		methods = append(methods, fastenMethod{id: id, uri: instances[i].uri, instantiation: instances[i].types})
 	}
 	for _, namespace := range namespaces {
-		fastenJSON.AddInterfaceToCHA(namespace, typeHierarchy.getTraitFromTypeHierarchy(node.RelativeDefId))
+		fastenJSON.AddInterfaceToCHA(namespace, typeHierarchy.getTraitFromTypeHierarchy(node.RelativeDefId, node.PackageVersion))
 	}
 
-	return ids
+	return methods
 }
 
 // Format file information from rust call graph.
@@ -198,19 +581,13 @@ func getFileName(rawFileName string, productVersion string) string {
 	}
 }
 
-// Resolve a timestamp for the given fastenJson
-func resolveTimestamp(fastenJSON *fasten.JSON) {
-	uri := "https://crates.io/api/v1/crates/" + fastenJSON.Product + "/" + fastenJSON.Version
-	resp, _ := http.Get(uri)
-	respBody, _ := ioutil.ReadAll(resp.Body)
-	var api CratesioAPI
-	_ = json.Unmarshal(respBody, &api)
-	_ = resp.Body.Close()
-
-	layout := "2006-01-02T15:04:05.999999999Z07:00"
-	date := api.Version.CreatedAt
-	if date != "" {
-		timestamp, _ := time.Parse(layout, date)
-		fastenJSON.Timestamp = timestamp.Unix()
+// Resolve a timestamp for the given fastenJson. The timestamp stays unresolved
+// when the provider is nil or fails.
+func resolveTimestamp(fastenJSON *fasten.JSON, provider TimestampProvider) {
+	if provider == nil {
+		return
+	}
+	if timestamp, err := provider.Timestamp(fastenJSON.Product, fastenJSON.Version); err == nil {
+		fastenJSON.Timestamp = timestamp
 	}
 }
@@ -0,0 +1,309 @@
+package rust
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kinds of segments of a def-path.
+type segmentKind int
+
+const (
+	segmentName segmentKind = iota
+	segmentImpl
+	segmentClosure
+	segmentConstructor
+	segmentConstant
+)
+
+// Names of the anonymous segments of a def-path, e.g. impl in {{impl}}.
+var anonymousSegments = map[segmentKind]string{
+	segmentImpl:        "impl",
+	segmentClosure:     "closure",
+	segmentConstructor: "constructor",
+	segmentConstant:    "constant",
+}
+
+// Notations of def-paths emitted by different rustc versions.
+type dialect int
+
+const (
+	// Segment valid in both notations, e.g. a name without disambiguator.
+	dialectAny dialect = iota
+	// Older rustc: crate[hash]::module[0]::{{impl}}[1]::{{closure}}[0].
+	dialectLegacy
+	// Newer rustc: crate::module::{impl#1}::{closure#0}, names as name#1.
+	dialectModern
+)
+
+// Segment of a def-path, e.g. module[0], {{impl}}[1] or {impl#1}. Disambiguator is the
+// content of the square brackets or the number following # in the segment.
+type segment struct {
+	kind          segmentKind
+	name          string
+	disambiguator string
+}
+
+// Parsed relative_def_id of rustc in format crate[hash]::module[0]::{{impl}}[1]::method[0]
+// or crate::module::{impl#1}::method. Segments follow the crate: modules, impl blocks,
+// types, functions, closures, constructors and constants.
+type defPath struct {
+	dialect  dialect
+	crate    segment
+	segments []segment
+}
+
+// Parses a relative_def_id in either dialect. Segments are separated by :: outside
+// of brackets, so types containing paths in generics do not split a segment.
+// Def-paths mixing both dialects are rejected.
+func parseDefPath(relativeDefId string) (defPath, error) {
+	var path defPath
+	tokens, err := splitDefPath(relativeDefId)
+	if err != nil {
+		return path, err
+	}
+	for i, token := range tokens {
+		parsed, notation, err := parseSegment(token)
+		if err != nil {
+			return path, errors.New("invalid def-path " + relativeDefId + ": " + err.Error())
+		}
+		if notation != dialectAny {
+			if path.dialect != dialectAny && path.dialect != notation {
+				return path, errors.New("invalid def-path " + relativeDefId + ": mixed dialects")
+			}
+			path.dialect = notation
+		}
+		if i == 0 {
+			if parsed.kind != segmentName {
+				return path, errors.New("invalid def-path " + relativeDefId + ": missing crate")
+			}
+			path.crate = parsed
+		} else {
+			path.segments = append(path.segments, parsed)
+		}
+	}
+	return path, nil
+}
+
+// Splits a def-path on :: at bracket depth 0.
+func splitDefPath(relativeDefId string) ([]string, error) {
+	var tokens []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(relativeDefId); i++ {
+		switch relativeDefId[i] {
+		case '<', '(', '[', '{':
+			depth++
+		case '>':
+			if i > 0 && relativeDefId[i-1] == '-' {
+				continue
+			}
+			depth--
+		case ')', ']', '}':
+			depth--
+		case ':':
+			if depth == 0 && i+1 < len(relativeDefId) && relativeDefId[i+1] == ':' {
+				tokens = append(tokens, relativeDefId[start:i])
+				start = i + 2
+				i++
+			}
+		}
+		if depth < 0 {
+			return nil, errors.New("unbalanced brackets in def-path " + relativeDefId)
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced brackets in def-path " + relativeDefId)
+	}
+	tokens = append(tokens, relativeDefId[start:])
+	for _, token := range tokens {
+		if token == "" {
+			return nil, errors.New("empty segment in def-path " + relativeDefId)
+		}
+	}
+	return tokens, nil
+}
+
+// Parses a segment in format name[disambiguator] or {{kind}}[disambiguator] of the
+// legacy dialect or name#disambiguator or {kind#disambiguator} of the modern dialect.
+// Returns the dialect of the notation. Other anonymous segments such as {{opaque}}
+// or {opaque#0} are kept as names in the legacy notation. Symbols mangled with the
+// v0 scheme are not demangled and rejected.
+func parseSegment(token string) (segment, dialect, error) {
+	var parsed segment
+	notation := dialectAny
+	if isV0Symbol(token) {
+		return parsed, notation, errors.New("segment " + token + " is a v0-mangled symbol")
+	}
+	name := token
+	if strings.HasSuffix(token, "]") {
+		depth := 0
+		for i := len(token) - 1; i >= 0; i-- {
+			if token[i] == ']' {
+				depth++
+			} else if token[i] == '[' {
+				depth--
+			}
+			if depth == 0 {
+				name, parsed.disambiguator = token[:i], token[i+1:len(token)-1]
+				notation = dialectLegacy
+				break
+			}
+		}
+	} else if hash := strings.LastIndex(token, "#"); hash > 0 && isNumber(strings.TrimSuffix(token[hash+1:], "}")) {
+		name, parsed.disambiguator = token[:hash], strings.TrimSuffix(token[hash+1:], "}")
+		if strings.HasSuffix(token, "}") {
+			name += "}"
+		}
+		notation = dialectModern
+	}
+	if name == "" {
+		return parsed, notation, errors.New("segment " + token + " has no name")
+	}
+
+	if strings.HasPrefix(name, "{{") && strings.HasSuffix(name, "}}") {
+		if notation == dialectAny {
+			notation = dialectLegacy
+		}
+		name = name[2 : len(name)-2]
+	} else if strings.HasPrefix(name, "{") && strings.HasSuffix(name, "}") && notation == dialectModern {
+		name = name[1 : len(name)-1]
+	} else {
+		parsed.kind = segmentName
+		parsed.name = name
+		return parsed, notation, nil
+	}
+	for kind, anonymous := range anonymousSegments {
+		if name == anonymous {
+			parsed.kind = kind
+			return parsed, notation, nil
+		}
+	}
+	parsed.kind = segmentName
+	parsed.name = "{{" + name + "}}"
+	return parsed, notation, nil
+}
+
+// Checks if the token is a symbol mangled with the v0 scheme of rustc, e.g.
+// _RNvCs1234_10crate_name4main: _R, an optional encoding version and a path
+// starting with its tag, consisting of letters, digits and underscores only.
+func isV0Symbol(token string) bool {
+	if !strings.HasPrefix(token, "_R") {
+		return false
+	}
+	path := strings.TrimLeft(token[2:], "0123456789")
+	if path == "" || !strings.ContainsRune("CMXYNIB", rune(path[0])) {
+		return false
+	}
+	for _, character := range path {
+		if character != '_' && !('a' <= character && character <= 'z') && !('A' <= character && character <= 'Z') && !('0' <= character && character <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks if the value is a non-empty decimal number.
+func isNumber(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, character := range value {
+		if character < '0' || character > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the name of the segment in Fasten URIs.
+func (s segment) String() string {
+	switch s.kind {
+	case segmentImpl:
+		return "{{impl}}"
+	case segmentClosure:
+		return "$closure" + s.disambiguator
+	case segmentConstructor:
+		return "EXPLICIT-CONSTRUCTOR"
+	case segmentConstant:
+		return "CONSTANT-FUNC"
+	}
+	return s.name
+}
+
+// Returns the canonical form of the segment used in keys. Disambiguators of
+// anonymous segments are kept, disambiguators of named segments only if not 0.
+func (s segment) key() string {
+	if s.kind != segmentName {
+		return "{{" + anonymousSegments[s.kind] + "}}[" + s.disambiguator + "]"
+	}
+	if s.disambiguator == "" || s.disambiguator == "0" {
+		return s.name
+	}
+	return s.name + "[" + s.disambiguator + "]"
+}
+
+// Returns the canonical key of the def-path up to and including the segment
+// at the index. Keys do not depend on the crate hash.
+func (path defPath) key(index int) string {
+	keys := []string{path.crate.name}
+	for _, s := range path.segments[:index+1] {
+		keys = append(keys, s.key())
+	}
+	return strings.Join(keys, "::")
+}
+
+// Returns the canonical key of the whole def-path.
+func (path defPath) fullKey() string {
+	return path.key(len(path.segments) - 1)
+}
+
+// Returns the canonical key of a def-path, or the def-path itself if it cannot be parsed.
+func defPathKey(relativeDefId string) string {
+	if path, err := parseDefPath(relativeDefId); err == nil {
+		return path.fullKey()
+	}
+	return relativeDefId
+}
+
+// Returns the def-path of the method with the name in the impl block, in the dialect
+// of the impl block.
+func implMethodDefId(implDefId string, name string) string {
+	if path, err := parseDefPath(implDefId); err == nil && path.dialect == dialectLegacy {
+		return implDefId + "::" + name + "[0]"
+	}
+	return implDefId + "::" + name
+}
+
+// Returns the index of the last impl block of the def-path or -1.
+func (path defPath) lastImpl() int {
+	for i := len(path.segments) - 1; i >= 0; i-- {
+		if path.segments[i].kind == segmentImpl {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the def-path of the function a closure is defined in, or false if the
+// def-path is not a closure.
+func closureParent(relativeDefId string) (string, bool) {
+	tokens, err := splitDefPath(relativeDefId)
+	if err != nil || len(tokens) < 2 {
+		return "", false
+	}
+	if parsed, _, err := parseSegment(tokens[len(tokens)-1]); err != nil || parsed.kind != segmentClosure {
+		return "", false
+	}
+	return strings.Join(tokens[:len(tokens)-1], "::"), true
+}
+
+// Checks if the def-path contains a segment of the kind.
+func (path defPath) contains(kind segmentKind) bool {
+	for _, s := range path.segments {
+		if s.kind == kind {
+			return true
+		}
+	}
+	return false
+}
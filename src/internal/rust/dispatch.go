@@ -0,0 +1,67 @@
+package rust
+
+// Method of a trait or of an impl block identified by its crate and def-path.
+type implementingMethod struct {
+	crate         crate
+	relativeDefId string
+}
+
+// Finds the methods implementing the trait method called by a dynamic call. The target
+// is a method of a trait or a method in an impl block of a trait in the given version of
+// its crate. Impls of that version of the trait are searched in the type hierarchy of the
+// package and of the standard library; impls found in both are returned once. Crates of
+// the standard library have version 0.0.0 like their nodes. Returns the method of each impl
+// and the method of the trait, which is called for impls not overriding it.
+func findImplementingMethods(relativeDefId string, version string, typeHierarchy MapTypeHierarchy, stdTypeHierarchy MapTypeHierarchy) ([]implementingMethod, implementingMethod) {
+	typeHierarchies := []MapTypeHierarchy{typeHierarchy, stdTypeHierarchy}
+	path, err := parseDefPath(relativeDefId)
+	if err != nil || len(path.segments) < 2 || path.segments[len(path.segments)-1].kind != segmentName {
+		return nil, implementingMethod{}
+	}
+	method := path.segments[len(path.segments)-1]
+	parent := len(path.segments) - 2
+
+	traitKey := typeHierarchy.pathKey(path, parent, version)
+	traitMethod := implementingMethod{crate: typeHierarchy.crateOf(path.crate.name, version), relativeDefId: relativeDefId}
+	if path.segments[parent].kind == segmentImpl {
+		traitKey = itemKey{}
+		for _, typeHierarchy := range typeHierarchies {
+			if impl, ok := typeHierarchy.Impls[typeHierarchy.pathKey(path, parent, version)]; ok && impl.TraitId != 0 {
+				trait := typeHierarchy.Traits[impl.TraitId]
+				traitKey = typeHierarchy.itemKey(trait.RelativeDefId, trait.PackageVersion)
+				traitKey.crate = typeHierarchy.nodeCrate(traitKey.crate)
+				traitMethod = implementingMethod{crate: traitKey.crate, relativeDefId: implMethodDefId(trait.RelativeDefId, method.name)}
+				break
+			}
+		}
+	}
+	if traitKey.path == "" {
+		return nil, implementingMethod{}
+	}
+
+	var methods []implementingMethod
+	found := make(map[itemKey]bool)
+	for _, typeHierarchy := range typeHierarchies {
+		key := itemKey{crate: typeHierarchy.crateOf(traitKey.crate.Name, traitKey.crate.Version), path: traitKey.path}
+		for _, impl := range typeHierarchy.TraitImpls[key] {
+			implKey := typeHierarchy.itemKey(impl.RelativeDefId, impl.PackageVersion)
+			implKey.crate = typeHierarchy.nodeCrate(implKey.crate)
+			if found[implKey] {
+				continue
+			}
+			found[implKey] = true
+
+			methods = append(methods, implementingMethod{
+				crate:         implKey.crate,
+				relativeDefId: implMethodDefId(impl.RelativeDefId, method.name),
+			})
+		}
+	}
+	return methods, traitMethod
+}
+
+// Returns the id of the node of the method in the call graph.
+func (method implementingMethod) nodeId(functions map[crate]map[string]int64) (int64, bool) {
+	id, exists := functions[method.crate][defPathKey(method.relativeDefId)]
+	return id, exists
+}
@@ -0,0 +1,131 @@
+package rust
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Type hierarchies of the standard library bundled into the binary.
+// standardlibrary/type_hierarchy.json is registered as DefaultToolchain,
+// standardlibrary/type_hierarchy-<toolchain>.json as the respective toolchain.
+//
+//go:embed standardlibrary/type_hierarchy*.json
+var embeddedStdTypeHierarchies embed.FS
+
+// Toolchain of the type hierarchy used when no toolchain is requested.
+const DefaultToolchain = "default"
+
+// Registry of type hierarchies of the standard library keyed by rustc toolchain version.
+// Type hierarchies are read and converted to maps on first use.
+type StdRegistry struct {
+	mutex       sync.Mutex
+	sources     map[string]func() ([]byte, error)
+	hierarchies map[string]MapTypeHierarchy
+}
+
+// Creates a registry containing the type hierarchies bundled into the binary.
+func NewStdRegistry() *StdRegistry {
+	registry := &StdRegistry{
+		sources:     make(map[string]func() ([]byte, error)),
+		hierarchies: make(map[string]MapTypeHierarchy),
+	}
+
+	files, _ := fs.Glob(embeddedStdTypeHierarchies, "standardlibrary/type_hierarchy*.json")
+	for _, file := range files {
+		toolchain := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "type_hierarchy"), ".json")
+		if toolchain == "" {
+			toolchain = DefaultToolchain
+		} else {
+			toolchain = strings.TrimPrefix(toolchain, "-")
+		}
+		path := file
+		registry.sources[toolchain] = func() ([]byte, error) {
+			return embeddedStdTypeHierarchies.ReadFile(path)
+		}
+	}
+	return registry
+}
+
+// Registers the type hierarchy stored in the file under the given toolchain.
+// Overrides a type hierarchy already registered for the toolchain.
+func (registry *StdRegistry) RegisterFile(toolchain string, path string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.sources[toolchain] = func() ([]byte, error) {
+		return ioutil.ReadFile(path)
+	}
+	delete(registry.hierarchies, toolchain)
+}
+
+// Registers every <toolchain>.json file of the directory under its toolchain.
+func (registry *StdRegistry) RegisterDirectory(directory string) error {
+	files, err := filepath.Glob(filepath.Join(directory, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no type hierarchies found in " + directory)
+	}
+	for _, file := range files {
+		registry.RegisterFile(strings.TrimSuffix(filepath.Base(file), ".json"), file)
+	}
+	return nil
+}
+
+// Returns the type hierarchy of the standard library of the given toolchain.
+// Empty toolchain returns the type hierarchy of DefaultToolchain.
+func (registry *StdRegistry) Get(toolchain string) (MapTypeHierarchy, error) {
+	if toolchain == "" {
+		toolchain = DefaultToolchain
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if typeHierarchy, ok := registry.hierarchies[toolchain]; ok {
+		return typeHierarchy, nil
+	}
+	source, ok := registry.sources[toolchain]
+	if !ok {
+		return MapTypeHierarchy{}, errors.New("no standard library type hierarchy for toolchain " + toolchain)
+	}
+
+	file, err := source()
+	if err != nil {
+		return MapTypeHierarchy{}, err
+	}
+	var rawTypeHierarchy TypeHierarchy
+	if err = json.Unmarshal(file, &rawTypeHierarchy); err != nil {
+		return MapTypeHierarchy{}, errors.New("standard library type hierarchy of toolchain " + toolchain + ": " + err.Error())
+	}
+	typeHierarchy := rawTypeHierarchy.ConvertStdToMap()
+	registry.hierarchies[toolchain] = typeHierarchy
+	return typeHierarchy, nil
+}
+
+// Checks if a type hierarchy is registered for the given toolchain.
+func (registry *StdRegistry) Has(toolchain string) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	_, ok := registry.sources[toolchain]
+	return ok
+}
+
+// Returns sorted toolchains present in the registry.
+func (registry *StdRegistry) Toolchains() []string {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	var toolchains []string
+	for toolchain := range registry.sources {
+		toolchains = append(toolchains, toolchain)
+	}
+	sort.Strings(toolchains)
+	return toolchains
+}
@@ -1,8 +1,8 @@
 package rust
 
 import (
+	"RustCallGraphConverter/src/internal/fasten"
 	"errors"
-	"net/url"
 	"regexp"
 	"strings"
 )
@@ -20,6 +20,8 @@ type Type struct {
 	PackageName    string `json:"package_name"`
 	PackageVersion string `json:"package_version"`
 	RelativeDefId  string `json:"relative_def_id"`
+	// Traits of a trait object type, e.g. dyn Display + Send. Optional.
+	TraitIds []int64 `json:"trait_ids"`
 }
 
 type Trait struct {
@@ -27,6 +29,8 @@ type Trait struct {
 	PackageName    string `json:"package_name"`
 	PackageVersion string `json:"package_version"`
 	RelativeDefId  string `json:"relative_def_id"`
+	// Direct supertraits of the trait. Optional.
+	SupertraitIds []int64 `json:"supertrait_ids"`
 }
 
 type Impl struct {
@@ -42,15 +46,54 @@ type Impl struct {
 type MapTypeHierarchy struct {
 	Types  map[int64]Type
 	Traits map[int64]Trait
-	Impls  map[string]Impl
+	Impls  map[itemKey]Impl
+	// Impls of each trait keyed by the key of the trait.
+	TraitImpls map[itemKey][]Impl
+	// Traits keyed by their crate and canonical def-path.
+	traitsByKey map[itemKey]Trait
+	// Type hierarchy of the standard library resolving its traits. Nil for
+	// the type hierarchy of the standard library itself.
+	std *MapTypeHierarchy
+	// Type hierarchy of the standard library. Its sysroot crates have version 0.0.0
+	// like the nodes of the standard library.
+	stdLibrary bool
+	// Versions of the crates vendored into the standard library by name. Nodes of
+	// the standard library have no version and resolve to these versions.
+	vendoredVersions map[string]string
+	// Attribute closures to the function they are defined in
+	// instead of converting them to methods of their own.
+	collapseClosures bool
+}
+
+// Key of an impl block or a trait: the crate of its def-path and the canonical key of
+// the def-path. Type hierarchies can contain several versions of one crate.
+type itemKey struct {
+	crate crate
+	path  string
 }
 
 // Convert data of type hierarchy read from json to a map for simplifying queries.
 func (typeHierarchy TypeHierarchy) ConvertToMap() MapTypeHierarchy {
+	return typeHierarchy.convertToMap(false)
+}
+
+// Converts the type hierarchy of the standard library to a map.
+func (typeHierarchy TypeHierarchy) ConvertStdToMap() MapTypeHierarchy {
+	return typeHierarchy.convertToMap(true)
+}
+
+// Converts the type hierarchy of a package or of the standard library to maps.
+func (typeHierarchy TypeHierarchy) convertToMap(stdLibrary bool) MapTypeHierarchy {
 	mapTypeHierarchy := MapTypeHierarchy{
-		Types:  make(map[int64]Type),
-		Traits: make(map[int64]Trait),
-		Impls:  make(map[string]Impl),
+		Types:       make(map[int64]Type),
+		Traits:      make(map[int64]Trait),
+		Impls:       make(map[itemKey]Impl),
+		TraitImpls:  make(map[itemKey][]Impl),
+		traitsByKey: make(map[itemKey]Trait),
+		stdLibrary:  stdLibrary,
+	}
+	if stdLibrary {
+		mapTypeHierarchy.vendoredVersions = typeHierarchy.vendoredVersions()
 	}
 
 	for _, typeInstance := range typeHierarchy.Types {
@@ -60,210 +103,338 @@ func (typeHierarchy TypeHierarchy) ConvertToMap() MapTypeHierarchy {
 
 	for _, traitInstance := range typeHierarchy.Traits {
 		mapTypeHierarchy.Traits[traitInstance.Id] = traitInstance
+		mapTypeHierarchy.traitsByKey[mapTypeHierarchy.itemKey(traitInstance.RelativeDefId, traitInstance.PackageVersion)] = traitInstance
 	}
 	typeHierarchy.Traits = nil
 
 	for _, implInstance := range typeHierarchy.Impls {
-		fourCharIdPattern := regexp.MustCompile("\\[.{4}]")
-		relativeDefId := fourCharIdPattern.ReplaceAllString(implInstance.RelativeDefId, "")
-		mapTypeHierarchy.Impls[relativeDefId] = implInstance
+		mapTypeHierarchy.Impls[mapTypeHierarchy.itemKey(implInstance.RelativeDefId, implInstance.PackageVersion)] = implInstance
+		if trait, ok := mapTypeHierarchy.Traits[implInstance.TraitId]; ok && implInstance.TraitId != 0 {
+			traitKey := mapTypeHierarchy.itemKey(trait.RelativeDefId, trait.PackageVersion)
+			mapTypeHierarchy.TraitImpls[traitKey] = append(mapTypeHierarchy.TraitImpls[traitKey], implInstance)
+		}
 	}
 	typeHierarchy.Impls = nil
 
 	return mapTypeHierarchy
 }
 
-// Converts a relativeDefId to the path in Fasten format.
-func (typeHierarchy MapTypeHierarchy) getFullPath(relativeDefId string) (string, error) {
-	var err error
-	modules, impl, nestedElements, method, err := typeHierarchy.parseRelativeDefPath(relativeDefId)
-
-	fullPath := "/"
-	fullPath += strings.Join(modules, ".")
-
-	if strings.Contains(impl, "[") {
-		patternBrackets := regexp.MustCompile("\\[(.*?)\\]")
-		index := patternBrackets.FindAllIndex([]byte(impl), -1)
-		if len(index) > 1 {
-			implElements := strings.Split(impl, "::")
-			lastElement := implElements[len(implElements)-1]
-			impl = patternBrackets.ReplaceAllString(lastElement, "")
-			impl = "[" + impl[:len(impl)-1] + "]"
+// Crates shipped with the toolchain. Crates vendored into the standard library,
+// like libc or hashbrown, keep their own versions.
+var sysrootCrates = map[string]bool{"core": true, "alloc": true, "std": true, "proc_macro": true, "test": true}
+
+// Returns the versions of the crates vendored into the standard library
+// recorded in its type hierarchy.
+func (typeHierarchy TypeHierarchy) vendoredVersions() map[string]string {
+	versions := make(map[string]string)
+	record := func(relativeDefId string, version string) {
+		if path, err := parseDefPath(relativeDefId); err == nil && version != "" && !sysrootCrates[path.crate.name] {
+			versions[path.crate.name] = version
 		}
+	}
+	for _, traitInstance := range typeHierarchy.Traits {
+		record(traitInstance.RelativeDefId, traitInstance.PackageVersion)
+	}
+	for _, implInstance := range typeHierarchy.Impls {
+		record(implInstance.RelativeDefId, implInstance.PackageVersion)
+	}
+	return versions
+}
 
-		index = patternBrackets.FindAllIndex([]byte(impl), -1)
-		for i := 0; i < len(index); i++ {
-			insideBrackets := impl[index[i][0]+1 : index[i][1]-1]
-			if strings.Contains(insideBrackets, "generic") {
-				patternColon := regexp.MustCompile(":")
-				indices := patternColon.FindAllIndex([]byte(insideBrackets), -1)
-				for _, genericIndex := range indices {
-					insideBrackets = insideBrackets[:genericIndex[0]] + "[]" + insideBrackets[genericIndex[0]:]
-				}
-				impl = impl[:index[i][0]] + insideBrackets + impl[index[i][1]:]
-			} else {
-				impl = impl[:index[i][0]] + insideBrackets + "[]" + impl[index[i][1]:]
-			}
+// Returns the crate with the name in the given version. Crates without a version,
+// like the crates of the standard library in call graphs, have version 0.0.0.
+// In the standard library type hierarchy the sysroot crates have version 0.0.0
+// and crates without a version or with version 0.0.0 the vendored version.
+func (typeHierarchy MapTypeHierarchy) crateOf(name string, version string) crate {
+	if typeHierarchy.stdLibrary {
+		if sysrootCrates[name] {
+			version = "0.0.0"
+		} else if version == "" || version == "0.0.0" {
+			version = typeHierarchy.vendoredVersions[name]
 		}
 	}
-	impl = url.PathEscape(impl)
-	impl = strings.ReplaceAll(impl, ":", "%3A")
-	impl = strings.ReplaceAll(impl, "&", "%26")
-	fullPath += "/" + impl
-
-	for _, element := range nestedElements {
-		if element[:1] == "$" {
-			element = url.PathEscape(element[1:])
-			element = strings.ReplaceAll(element, ":", "%3A")
-			element = strings.ReplaceAll(element, "&", "%26")
-			fullPath += "$" + element
-		} else {
-			element = url.PathEscape(element)
-			element = strings.ReplaceAll(element, ":", "%3A")
-			element = strings.ReplaceAll(element, "&", "%26")
-			fullPath += "." + url.PathEscape(element)
-		}
+	if version == "" {
+		version = "0.0.0"
 	}
-	fullPath += "." + method + "()"
+	return crate{Name: name, Version: version}
+}
 
-	if strings.Contains(fullPath, "{") ||
-		strings.Contains(fullPath, "}") ||
-		strings.Contains(fullPath, ":") {
-		panic(errors.New("illegal character in full path"))
+// Returns the crate of the nodes of a crate of the type hierarchy. Nodes of
+// the standard library have no version, so its crates have version 0.0.0.
+func (typeHierarchy MapTypeHierarchy) nodeCrate(itemCrate crate) crate {
+	if typeHierarchy.stdLibrary {
+		itemCrate.Version = "0.0.0"
 	}
+	return itemCrate
+}
 
-	return fullPath, err
+// Returns the key of the def-path prefix ending with the segment at the index
+// in the given version of its crate.
+func (typeHierarchy MapTypeHierarchy) pathKey(path defPath, index int, version string) itemKey {
+	return itemKey{crate: typeHierarchy.crateOf(path.crate.name, version), path: path.key(index)}
+}
+
+// Returns the key of the def-path in the given version of its crate.
+func (typeHierarchy MapTypeHierarchy) itemKey(relativeDefId string, version string) itemKey {
+	path, err := parseDefPath(relativeDefId)
+	if err != nil {
+		return itemKey{crate: typeHierarchy.crateOf("", version), path: relativeDefId}
+	}
+	return typeHierarchy.pathKey(path, len(path.segments)-1, version)
 }
 
-// Parses relativeDefId and returns a tuple containing slice of modules,
-// resolved type name, nested functions and types, function name.
-func (typeHierarchy MapTypeHierarchy) parseRelativeDefPath(relativeDefId string) ([]string, string, []string, string, error) {
-	patternClosure := regexp.MustCompile("::{{closure}}\\[[0-9]*]")
-	squareBracketsPattern := regexp.MustCompile("\\[.*?]")
+// Error returned when an impl block of a def-path is missing in the type hierarchy.
+var errNoType = errors.New("no type found")
 
-	var formattedRelativeDefId string
-	formattedRelativeDefId = patternClosure.ReplaceAllString(relativeDefId, "")
-	formattedRelativeDefId = squareBracketsPattern.ReplaceAllString(formattedRelativeDefId, "")
-	formattedRelativeDefId = strings.ReplaceAll(formattedRelativeDefId, "{{constructor}}","EXPLICIT-CONSTRUCTOR")
-	formattedRelativeDefId = strings.ReplaceAll(formattedRelativeDefId, "{{constant}}", "CONSTANT-FUNC")
+// Converts a relativeDefId in the given version of its crate to the URI of the method
+// in Fasten format. Returns errNoType with a URI containing UNKNOWN types if an impl
+// block is missing in the type hierarchy.
+func (typeHierarchy MapTypeHierarchy) getFullPath(relativeDefId string, version string) (fasten.URI, error) {
+	path, err := parseDefPath(relativeDefId)
+	if err != nil {
+		return fasten.URI{}, err
+	}
+	modules, impl, nestedElements, method, err := typeHierarchy.parseRelativeDefPath(path, version)
+	if err != nil && err != errNoType {
+		return fasten.URI{}, errors.New("invalid def-path " + relativeDefId + ": " + err.Error())
+	}
 
-	rawElements := strings.Split(relativeDefId, "::")
-	elements := strings.Split(formattedRelativeDefId, "::")
+	uri := fasten.URI{Namespace: strings.Join(modules, "."), Entity: formatType(impl) + strings.Join(nestedElements, "") + "." + method, Arguments: []string{}}
 
-	var err error = nil
-	if len(elements) < 2 {
-		err = errors.New("incorrect path")
+	if strings.ContainsAny(uri.Namespace+method, "{}:") {
+		return fasten.URI{}, errors.New("illegal character in full path of " + relativeDefId)
 	}
 
-	var gotFirstImpl = false
-	var relativeDefPathCurrentLength = 0
+	return uri, err
+}
+
+// Formats the type of an impl block for the entity of a URI. Brackets are removed
+// from the type and [] is appended to slices and inserted before bounds of generic types.
+func formatType(impl string) string {
+	if !strings.Contains(impl, "[") {
+		return impl
+	}
+	patternBrackets := regexp.MustCompile("\\[(.*?)\\]")
+	index := patternBrackets.FindAllIndex([]byte(impl), -1)
+	if len(index) > 1 {
+		implElements := strings.Split(impl, "::")
+		lastElement := implElements[len(implElements)-1]
+		impl = patternBrackets.ReplaceAllString(lastElement, "")
+		impl = "[" + impl[:len(impl)-1] + "]"
+	}
+
+	index = patternBrackets.FindAllIndex([]byte(impl), -1)
+	for i := 0; i < len(index); i++ {
+		insideBrackets := impl[index[i][0]+1 : index[i][1]-1]
+		if strings.Contains(insideBrackets, "generic") {
+			patternColon := regexp.MustCompile(":")
+			indices := patternColon.FindAllIndex([]byte(insideBrackets), -1)
+			for _, genericIndex := range indices {
+				insideBrackets = insideBrackets[:genericIndex[0]] + "[]" + insideBrackets[genericIndex[0]:]
+			}
+			impl = impl[:index[i][0]] + insideBrackets + impl[index[i][1]:]
+		} else {
+			impl = impl[:index[i][0]] + insideBrackets + "[]" + impl[index[i][1]:]
+		}
+	}
+	return impl
+}
+
+// Splits a parsed def-path into a tuple containing slice of modules, resolved type name,
+// nested functions and types with their separators, function name. Closures at the end
+// of the def-path become the function nested in their parent, e.g. outer().$closure0,
+// unless closures are collapsed. Other closures are skipped. Impl blocks are looked up
+// in the given version of the crate. Returns errNoType if an impl block is missing in
+// the type hierarchy.
+func (typeHierarchy MapTypeHierarchy) parseRelativeDefPath(path defPath, version string) ([]string, string, []string, string, error) {
+	closures := len(path.segments)
+	if !typeHierarchy.collapseClosures {
+		for closures > 0 && path.segments[closures-1].kind == segmentClosure {
+			closures--
+		}
+	}
+	var items []int
+	for i, s := range path.segments[:closures] {
+		if s.kind != segmentClosure {
+			items = append(items, i)
+		}
+	}
+	if len(items) == 0 {
+		return nil, "", nil, "", errors.New("no item")
+	}
+	last := path.segments[items[len(items)-1]]
+	if last.kind == segmentImpl {
+		return nil, "", nil, "", errors.New("ends with an impl block")
+	}
 
+	var err error
+	var gotFirstImpl = false
 	var modules = make([]string, 0)
 	var impl string
 	var nestedElements []string
-	var function = elements[len(elements)-1]
 
-	for i := 1; i < len(elements)-1; i++ {
-		relativeDefPathCurrentLength++
-		if elements[i] != "" {
+	for _, i := range items[:len(items)-1] {
+		s := path.segments[i]
+		if s.kind == segmentImpl {
+			implType, implErr := typeHierarchy.getTypeFromTypeHierarchy(typeHierarchy.pathKey(path, i, version))
+			if implErr != nil {
+				err = implErr
+			}
 			if !gotFirstImpl {
-				if strings.Contains(elements[i], "{{impl}}") {
-					gotFirstImpl = true
-					currentRelativeDefId := strings.Join(rawElements[:relativeDefPathCurrentLength+1], "::")
-					impl, err = typeHierarchy.getTypeFromTypeHierarchy(currentRelativeDefId)
-				} else {
-					modules = append(modules, url.PathEscape(elements[i]))
-				}
+				gotFirstImpl = true
+				impl = implType
 			} else {
-				if strings.Contains(elements[i], "{{impl}}") {
-					var nestedType string
-					currentRelativeDefId := strings.Join(rawElements[:relativeDefPathCurrentLength+1], "::")
-					nestedType, err = typeHierarchy.getTypeFromTypeHierarchy(currentRelativeDefId)
-
-					nestedElements = append(nestedElements, "$"+nestedType)
-				} else {
-					nestedElements = append(nestedElements, elements[i]+"()")
-				}
+				nestedElements = append(nestedElements, "$"+implType)
 			}
+		} else if !gotFirstImpl {
+			modules = append(modules, s.String())
+		} else {
+			nestedElements = append(nestedElements, "."+s.String()+"()")
 		}
 	}
 	if !gotFirstImpl {
 		impl = "NO-TYPE-DEFINITION"
 	}
 
-	if strings.Contains(relativeDefId, "{{constructor}}") && len(modules) > 0 {
-		impl = modules[len(modules) - 1]
-		modules = modules[:len(modules) - 1]
+	if path.contains(segmentConstructor) && len(modules) > 0 {
+		impl = modules[len(modules)-1]
+		modules = modules[:len(modules)-1]
 	}
 
 	if len(modules) == 0 {
 		modules = append(modules, "EMPTY-NAMESPACE")
 	}
- 	return modules, impl, nestedElements, function, err
-}
-
-// When {{impl}}[id] is present in the relativeDefPath finds the respective implementation
-// in the list of Impls inside the type hierarchy. Returns the respective Type and Trait.
-func (typeHierarchy MapTypeHierarchy) getTypeFromTypeHierarchy(relativeDefId string) (string, error) {
-	pattern := regexp.MustCompile("^.*{{impl}}\\[[0-9]*]")
-	fourCharIdPattern := regexp.MustCompile("\\[.{4}]")
 
-	relativeDefId = pattern.FindString(relativeDefId)
-	relativeDefId = fourCharIdPattern.ReplaceAllString(relativeDefId, "")
+	method := last.String()
+	for _, closure := range path.segments[closures:] {
+		nestedElements = append(nestedElements, "."+method+"()")
+		method = closure.String()
+	}
+	return modules, impl, nestedElements, method, err
+}
 
-	if implementation, ok := typeHierarchy.Impls[relativeDefId]; ok {
+// Finds the implementation with the key in the list of Impls inside
+// the type hierarchy. Returns the respective Type.
+func (typeHierarchy MapTypeHierarchy) getTypeFromTypeHierarchy(key itemKey) (string, error) {
+	if implementation, ok := typeHierarchy.Impls[key]; ok {
 		return typeHierarchy.Types[implementation.TypeId].StringId, nil
 	}
-	return "UNKNOWN", errors.New("no type found")
+	return "UNKNOWN", errNoType
 }
 
-// When {{impl}}[id] is present in the relativeDefPath finds the respective implementation
-// in the list of Impls inside the type hierarchy. Returns the respective Type and Trait.
-func (typeHierarchy MapTypeHierarchy) getTraitFromTypeHierarchy(relativeDefId string) string {
-	pattern := regexp.MustCompile("^.*{{impl}}\\[[0-9]*]")
-	fourCharIdPattern := regexp.MustCompile("\\[.{4}]")
-	relativeDefId = pattern.FindString(relativeDefId)
-	relativeDefId = fourCharIdPattern.ReplaceAllString(relativeDefId, "")
-	if implementation, ok := typeHierarchy.Impls[relativeDefId]; ok {
+// Finds the implementation of the last impl block of the relativeDefId in the given version
+// of its crate in the list of Impls inside the type hierarchy. Returns the path of its Trait
+// or an empty string.
+func (typeHierarchy MapTypeHierarchy) getTraitFromTypeHierarchy(relativeDefId string, version string) string {
+	path, err := parseDefPath(relativeDefId)
+	if err != nil || path.lastImpl() < 0 {
+		return ""
+	}
+	if implementation, ok := typeHierarchy.Impls[typeHierarchy.pathKey(path, path.lastImpl(), version)]; ok {
 		if implementation.TraitId != 0 {
-			id := implementation.TraitId
-			return typeHierarchy.getTraitPath(typeHierarchy.Traits[id-typeHierarchy.Traits[0].Id].RelativeDefId)
+			return typeHierarchy.getTraitURI(typeHierarchy.Traits[implementation.TraitId], typeHierarchy.crateOf(path.crate.name, version))
 		}
 	}
 	return ""
 }
 
+// Converts a Trait to its URI in Fasten format in the graph of the crate. Traits of other
+// crates, including other versions of the crate, are external URIs. Traits of the standard
+// library are resolved through its type hierarchy and have version 0.0.0 like the nodes
+// of the standard library.
+func (typeHierarchy MapTypeHierarchy) getTraitURI(trait Trait, graphCrate crate) string {
+	path, err := parseDefPath(trait.RelativeDefId)
+	if err != nil {
+		return ""
+	}
+	traitCrate := typeHierarchy.crateOf(path.crate.name, trait.PackageVersion)
+	if traitCrate == graphCrate {
+		return typeHierarchy.getTraitPath(trait.RelativeDefId, trait.PackageVersion)
+	}
+
+	resolver := typeHierarchy
+	if typeHierarchy.std != nil && traitCrate.Version == "0.0.0" {
+		if stdTrait, ok := typeHierarchy.std.traitsByKey[typeHierarchy.std.pathKey(path, len(path.segments)-1, "")]; ok {
+			resolver, trait = *typeHierarchy.std, stdTrait
+		}
+	}
+	uri, err := fasten.ParseURI(resolver.getTraitPath(trait.RelativeDefId, trait.PackageVersion))
+	if err != nil {
+		return ""
+	}
+	uri.Forge, uri.Product, uri.Version = "cratesio", traitCrate.Name, traitCrate.Version
+	return uri.String()
+}
+
+// Returns the traits and all their supertraits, each trait once and
+// supertraits after the traits they are inherited by.
+func (typeHierarchy MapTypeHierarchy) getSupertraits(traitIds []int64) []Trait {
+	var traits []Trait
+	found := make(map[int64]bool)
+	queue := append([]int64{}, traitIds...)
+	for i := 0; i < len(queue); i++ {
+		trait, ok := typeHierarchy.Traits[queue[i]]
+		if !ok || found[queue[i]] {
+			continue
+		}
+		found[queue[i]] = true
+		traits = append(traits, trait)
+		queue = append(queue, trait.SupertraitIds...)
+	}
+	return traits
+}
+
+// Returns the namespaces of the type of an impl block in the given version of its
+// crate, one for each instantiation of its generic types.
+func (typeHierarchy MapTypeHierarchy) getImplNamespaces(relativeDefId string, version string) []string {
+	methodDefId := implMethodDefId(relativeDefId, "method")
+	uri, err := typeHierarchy.getFullPath(methodDefId, version)
+	if err != nil {
+		return nil
+	}
+	if !typeHierarchy.isGenericType(methodDefId, version) {
+		return []string{getNamespace(uri)}
+	}
+	var namespaces []string
+	for _, instance := range typeHierarchy.getGenericInstances(uri) {
+		namespaces = append(namespaces, getNamespace(instance.uri))
+	}
+	return namespaces
+}
+
 // Extract the namespace from the full type info by removing the function name
 // at the end.
-func getNamespace(method string) string {
-	index := strings.LastIndex(method, ".")
-	return method[:index]
+func getNamespace(method fasten.URI) string {
+	return method.Type().String()
 }
 
-// Convert relativeDefId of a Trait to Fasten format.
-func (typeHierarchy MapTypeHierarchy) getTraitPath(relativeDefId string) string {
-	fullPath, _ := typeHierarchy.getFullPath(relativeDefId)
-	fullPath = fullPath[:len(fullPath) - 2]
+// Convert relativeDefId of a Trait in the given version of its crate to Fasten format.
+func (typeHierarchy MapTypeHierarchy) getTraitPath(relativeDefId string, version string) string {
+	uri, err := typeHierarchy.getFullPath(relativeDefId, version)
+	if err != nil && err != errNoType {
+		return ""
+	}
+	uri.Arguments = nil
 
-	if strings.Contains(fullPath, "NO-TYPE-DEFINITION.") {
-		fullPath = strings.ReplaceAll(fullPath, "NO-TYPE-DEFINITION.", "")
+	if strings.Contains(uri.Entity, "NO-TYPE-DEFINITION.") {
+		uri.Entity = strings.ReplaceAll(uri.Entity, "NO-TYPE-DEFINITION.", "")
 	} else {
-		lastDot := strings.LastIndex(fullPath, ".")
-		fullPath = fullPath[:lastDot] + "$" + fullPath[lastDot + 1:]
+		lastDot := strings.LastIndex(uri.Entity, ".")
+		uri.Entity = uri.Entity[:lastDot] + "$" + uri.Entity[lastDot+1:]
 	}
 
-	return fullPath
+	return uri.String()
 }
 
-// Check if the given RelativeDefId contains generic types.
-func (typeHierarchy MapTypeHierarchy) isGenericType(relativeDefId string) bool {
-	rawElements := strings.Split(relativeDefId, "::")
-	length := 0
-	for _, elem := range rawElements {
-		length++
-		if strings.Contains(elem, "{{impl}}") {
-			currentRelativeDefId := strings.Join(rawElements[:length+1], "::")
-			resolvedType, _ := typeHierarchy.getTypeFromTypeHierarchy(currentRelativeDefId)
+// Check if the given RelativeDefId in the given version of its crate contains generic types.
+func (typeHierarchy MapTypeHierarchy) isGenericType(relativeDefId string, version string) bool {
+	path, err := parseDefPath(relativeDefId)
+	if err != nil {
+		return false
+	}
+	for i, s := range path.segments {
+		if s.kind == segmentImpl {
+			resolvedType, _ := typeHierarchy.getTypeFromTypeHierarchy(typeHierarchy.pathKey(path, i, version))
 			if len(resolvedType) > 2 && resolvedType[:1] == "(" {
 				return true
 			}
@@ -272,37 +443,44 @@ func (typeHierarchy MapTypeHierarchy) isGenericType(relativeDefId string) bool {
 	return false
 }
 
-// Converts a path containing generic types to a slice of
-// paths each containing one generic type.
-func (typeHierarchy MapTypeHierarchy) getGenericFullPaths(fullPath string) []string {
-	var types []string
-	implPattern := regexp.MustCompile("(/|\\$)%28.+?%29")
-	resolvedGenericTypes := implPattern.FindAllString(fullPath, -1)
-	resolvedGenericTypesIndices := implPattern.FindAllIndex([]byte(fullPath), -1)
+// Instance of a method with generic types. Types are the generic types
+// substituted into the URI.
+type genericInstance struct {
+	uri   fasten.URI
+	types []string
+}
+
+// Converts a URI containing generic types to a slice of instances
+// each containing one generic type.
+func (typeHierarchy MapTypeHierarchy) getGenericInstances(uri fasten.URI) []genericInstance {
+	var instances []genericInstance
+	implPattern := regexp.MustCompile("(^|\\$)\\(.+?\\)")
+	resolvedGenericTypesIndices := implPattern.FindAllStringIndex(uri.Entity, -1)
 
-	if len(resolvedGenericTypes) == 0 {
-		return []string{fullPath}
+	if len(resolvedGenericTypesIndices) == 0 {
+		return []genericInstance{{uri: uri}}
 	}
 
 	index := resolvedGenericTypesIndices[len(resolvedGenericTypesIndices)-1]
-	alreadyResolvedPath := fullPath[index[1]:]
-	symbol := resolvedGenericTypes[len(resolvedGenericTypes)-1][:1]
-	resolved, _ := url.PathUnescape(resolvedGenericTypes[len(resolvedGenericTypes)-1][1:])
-	resolved = strings.ReplaceAll(resolved, "%3A", ":")
-	resolved = strings.ReplaceAll(resolved, "%26", "&")
+	alreadyResolvedEntity := uri.Entity[index[1]:]
+	resolved := uri.Entity[index[0]:index[1]]
+	symbol := ""
+	if resolved[:1] == "$" {
+		symbol = "$"
+		resolved = resolved[1:]
+	}
 	genericTypes := strings.Split(resolved[1:len(resolved)-3], ",")
 
 	for _, genericType := range genericTypes {
 		genericType = strings.TrimSpace(genericType)
-		genericPath := fullPath[:index[0]] + symbol + url.PathEscape(genericType)
-		// Manual encoding, because : and & and not ignored by url.PathEncode
-		genericPath = strings.ReplaceAll(genericPath, ":", "%3A")
-		genericPath = strings.ReplaceAll(genericPath, "&", "%26")
-
-		resolvedGenericPath := typeHierarchy.getGenericFullPaths(genericPath)
-		for _, path := range resolvedGenericPath {
-			types = append(types, path+alreadyResolvedPath)
+		genericURI := uri
+		genericURI.Entity = uri.Entity[:index[0]] + symbol + genericType
+
+		for _, instance := range typeHierarchy.getGenericInstances(genericURI) {
+			instance.uri.Entity += alreadyResolvedEntity
+			instance.types = append(instance.types, genericType)
+			instances = append(instances, instance)
 		}
 	}
-	return types
+	return instances
 }
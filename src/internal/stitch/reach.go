@@ -0,0 +1,186 @@
+package stitch
+
+import (
+	"RustCallGraphConverter/src/internal/cargo"
+	"RustCallGraphConverter/src/internal/fasten"
+	"errors"
+	"sort"
+)
+
+// Answer of a reachability query for a single target. Chain lists the URIs of the
+// methods on a shortest call chain from an entry point to the target.
+type Witness struct {
+	Target    string   `json:"target"`
+	Reachable bool     `json:"reachable"`
+	Chain     []string `json:"chain"`
+}
+
+// Checks which targets are reachable from the entry points in the stitched graph and
+// returns a witness for each target. Entry points default to all methods of the root
+// package. Targets and entry points are URIs which may omit the forge, the version
+// or the arguments; URIs without product refer to the root package. Targets are also
+// matched against unresolved calls, so methods of packages missing in the store can
+// be queried.
+func Reach(graph *Graph, entries []string, targets []string) ([]Witness, error) {
+	entryPatterns, err := parsePatterns(entries, graph.root)
+	if err != nil {
+		return nil, err
+	}
+	targetPatterns, err := parsePatterns(targets, graph.root)
+	if err != nil {
+		return nil, err
+	}
+	uris := parseNodes(graph)
+
+	var sources []int64
+	for _, id := range sortedNodes(graph) {
+		if len(entries) == 0 && uris[id].Product == graph.root.Product && uris[id].Version == graph.root.Version {
+			sources = append(sources, id)
+		}
+		for _, pattern := range entryPatterns {
+			if matches(pattern, uris[id]) {
+				sources = append(sources, id)
+				break
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("no entry point found in the stitched graph")
+	}
+
+	order, parents := search(graph, sources)
+	unresolved := make(map[int64][]string)
+	for _, call := range graph.UnresolvedCalls {
+		unresolved[call.Source] = append(unresolved[call.Source], call.Target)
+	}
+
+	witnesses := make([]Witness, 0, len(targets))
+	for i, pattern := range targetPatterns {
+		witness := Witness{Target: targets[i], Chain: []string{}}
+		for _, id := range order {
+			if matches(pattern, uris[id]) {
+				witness.Reachable, witness.Chain = true, chain(graph, parents, id)
+				break
+			}
+			if call := matchUnresolved(pattern, unresolved[id]); call != "" {
+				witness.Reachable, witness.Chain = true, append(chain(graph, parents, id), call)
+				break
+			}
+		}
+		witnesses = append(witnesses, witness)
+	}
+	return witnesses, nil
+}
+
+// Searches the internal and resolved calls breadth-first from the sources. Returns the
+// visited nodes ordered by their distance from the sources and the parent of each node.
+// Closures are reachable from the function they are defined in.
+func search(graph *Graph, sources []int64) ([]int64, map[int64]int64) {
+	successors := make(map[int64][]int64)
+	for _, calls := range [][][]interface{}{graph.InternalCalls, graph.ResolvedCalls} {
+		for _, call := range calls {
+			source, target := call[0].(int64), call[1].(int64)
+			successors[source] = append(successors[source], target)
+		}
+	}
+	for _, pair := range graph.DefinedIn {
+		successors[pair[1]] = append(successors[pair[1]], pair[0])
+	}
+
+	parents := make(map[int64]int64)
+	order := make([]int64, 0, len(graph.Nodes))
+	for _, source := range sources {
+		parents[source] = source
+		order = append(order, source)
+	}
+	for i := 0; i < len(order); i++ {
+		for _, successor := range successors[order[i]] {
+			if _, visited := parents[successor]; !visited {
+				parents[successor] = order[i]
+				order = append(order, successor)
+			}
+		}
+	}
+	return order, parents
+}
+
+// Returns the URIs of the methods on the path from a source to the node.
+func chain(graph *Graph, parents map[int64]int64, node int64) []string {
+	path := []string{graph.Nodes[node]}
+	for parents[node] != node {
+		node = parents[node]
+		path = append([]string{graph.Nodes[node]}, path...)
+	}
+	return path
+}
+
+// Returns the first target of unresolved calls matching the pattern, or an empty string.
+func matchUnresolved(pattern fasten.URI, targets []string) string {
+	for _, target := range targets {
+		if uri, err := fasten.ParseURI(target); err == nil && matches(pattern, uri) {
+			return target
+		}
+	}
+	return ""
+}
+
+// Parses URIs of a query. URIs without product refer to the root package.
+func parsePatterns(values []string, root fasten.URI) ([]fasten.URI, error) {
+	patterns := make([]fasten.URI, len(values))
+	for i, value := range values {
+		pattern, err := fasten.ParseURI(value)
+		if err != nil {
+			return nil, err
+		}
+		if !pattern.IsExternal() {
+			pattern.Forge, pattern.Product, pattern.Version = root.Forge, root.Product, root.Version
+		}
+		patterns[i] = pattern
+	}
+	return patterns, nil
+}
+
+// Checks if the URI matches the pattern. Forge, version and arguments missing
+// in the pattern match any value.
+func matches(pattern fasten.URI, uri fasten.URI) bool {
+	if cargo.CrateName(pattern.Product) != cargo.CrateName(uri.Product) ||
+		pattern.Namespace != uri.Namespace || pattern.Entity != uri.Entity ||
+		(pattern.Forge != "" && pattern.Forge != uri.Forge) ||
+		(pattern.Version != "" && pattern.Version != uri.Version) {
+		return false
+	}
+	if pattern.Arguments == nil {
+		return true
+	}
+	if len(pattern.Arguments) != len(uri.Arguments) {
+		return false
+	}
+	for i, argument := range pattern.Arguments {
+		if argument != uri.Arguments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Parses the URIs of all nodes of the stitched graph. Malformed URIs are left empty
+// and match no query.
+func parseNodes(graph *Graph) map[int64]fasten.URI {
+	uris := make(map[int64]fasten.URI, len(graph.Nodes))
+	for id, node := range graph.Nodes {
+		if uri, err := fasten.ParseURI(node); err == nil {
+			uris[id] = uri
+		}
+	}
+	return uris
+}
+
+// Returns the ids of the nodes in ascending order.
+func sortedNodes(graph *Graph) []int64 {
+	ids := make([]int64, 0, len(graph.Nodes))
+	for id := range graph.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,203 @@
+package stitch
+
+import (
+	"RustCallGraphConverter/src/internal/cargo"
+	"RustCallGraphConverter/src/internal/fasten"
+	"sort"
+	"strconv"
+)
+
+// Reasons of unresolved calls and missing dependencies.
+const (
+	ReasonMissingPackage = "missing-package"
+	ReasonMissingMethod  = "missing-method"
+	ReasonInvalidURI     = "invalid-uri"
+	ReasonInvalidGraph   = "invalid-graph"
+)
+
+// Call graph of a package and its dependencies. Nodes are methods of all packages
+// identified by external URIs. Calls within a package are internal, calls into the
+// graph of a dependency are resolved and all other calls are unresolved. DefinedIn
+// pairs each closure with the function it is defined in.
+type Graph struct {
+	Root                string              `json:"root"`
+	Packages            []string            `json:"packages"`
+	Nodes               map[int64]string    `json:"nodes"`
+	InternalCalls       [][]interface{}     `json:"internalCalls"`
+	ResolvedCalls       [][]interface{}     `json:"resolvedCalls"`
+	DefinedIn           [][]int64           `json:"definedIn"`
+	UnresolvedCalls     []UnresolvedCall    `json:"unresolvedCalls"`
+	MissingDependencies []MissingDependency `json:"missingDependencies"`
+	root                fasten.URI
+	nodes               map[string]int64
+}
+
+// External call which could not be resolved to a method of a dependency.
+type UnresolvedCall struct {
+	Source   int64                  `json:"source"`
+	Target   string                 `json:"target"`
+	Reason   string                 `json:"reason"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// Dependency of a package whose graph is not in the store.
+type MissingDependency struct {
+	Dependent  string            `json:"dependent"`
+	Dependency fasten.Dependency `json:"dependency"`
+	Reason     string            `json:"reason"`
+	Message    string            `json:"message,omitempty"`
+}
+
+// Package added to the stitched graph with the global ids of its methods.
+type stitchedPackage struct {
+	graph *fasten.JSON
+	ids   map[int64]int64
+}
+
+// Stitches the graph of the root package with the graphs of its dependencies found in
+// the store. Dependencies of the depsets are followed transitively, and so are packages
+// referenced by URIs of external calls.
+func Stitch(root *fasten.JSON, store *Store) *Graph {
+	stitched := &Graph{
+		Root:                root.Product + "$" + root.Version,
+		Packages:            []string{},
+		Nodes:               make(map[int64]string),
+		InternalCalls:       [][]interface{}{},
+		ResolvedCalls:       [][]interface{}{},
+		DefinedIn:           [][]int64{},
+		UnresolvedCalls:     []UnresolvedCall{},
+		MissingDependencies: []MissingDependency{},
+		root:                fasten.URI{Forge: root.Forge, Product: root.Product, Version: root.Version},
+		nodes:               make(map[string]int64),
+	}
+	packages := make(map[string]*stitchedPackage)
+	queue := []*stitchedPackage{stitched.addPackage(packages, root)}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		dependent := current.graph.Product + "$" + current.graph.Version
+
+		for _, inner := range current.graph.Depset {
+			for _, dependency := range inner {
+				version, ok := store.Resolve(dependency)
+				if !ok {
+					stitched.addMissing(dependent, dependency, ReasonMissingPackage, "")
+					continue
+				}
+				if _, exists := packages[packageKey(dependency.Product, version)]; exists {
+					continue
+				}
+				graph, err := store.Load(dependency.Product, version)
+				if err == ErrNotFound {
+					stitched.addMissing(dependent, dependency, ReasonMissingPackage, "")
+				} else if err != nil {
+					stitched.addMissing(dependent, dependency, ReasonInvalidGraph, err.Error())
+				} else {
+					queue = append(queue, stitched.addPackage(packages, graph))
+				}
+			}
+		}
+
+		for _, call := range current.graph.Graph.InternalCalls {
+			source, _ := call[0].(int64)
+			target, _ := call[1].(int64)
+			stitched.InternalCalls = append(stitched.InternalCalls,
+				[]interface{}{current.ids[source], current.ids[target], call[2]})
+		}
+
+		for _, call := range current.graph.Graph.ExternalCalls {
+			local, _ := strconv.ParseInt(call[0].(string), 10, 64)
+			source := current.ids[local]
+			target := call[1].(string)
+			metadata, _ := call[2].(map[string]interface{})
+
+			uri, err := fasten.ParseURI(target)
+			if err != nil || !uri.IsExternal() {
+				stitched.addUnresolved(source, target, ReasonInvalidURI, metadata)
+				continue
+			}
+			key := packageKey(uri.Product, uri.Version)
+			if _, exists := packages[key]; !exists {
+				if graph, err := store.Load(uri.Product, uri.Version); err == nil {
+					queue = append(queue, stitched.addPackage(packages, graph))
+				}
+			}
+			if _, exists := packages[key]; !exists {
+				stitched.addUnresolved(source, target, ReasonMissingPackage, metadata)
+				continue
+			}
+
+			uri.Forge, uri.Product, uri.Version = "", "", ""
+			targetId, exists := packages[key].graph.DuplicateCHA[uri.String()]
+			if !exists {
+				stitched.addUnresolved(source, target, ReasonMissingMethod, metadata)
+				continue
+			}
+			stitched.ResolvedCalls = append(stitched.ResolvedCalls,
+				[]interface{}{source, packages[key].ids[targetId], metadata})
+		}
+	}
+	return stitched
+}
+
+// Adds the methods of the graph as nodes with global ids and the closures
+// of the graph with the functions they are defined in.
+func (stitched *Graph) addPackage(packages map[string]*stitchedPackage, graph *fasten.JSON) *stitchedPackage {
+	added := &stitchedPackage{graph: graph, ids: make(map[int64]int64)}
+	packages[packageKey(graph.Product, graph.Version)] = added
+	stitched.Packages = append(stitched.Packages, graph.Product+"$"+graph.Version)
+
+	methods := make(map[int64]string)
+	definedIn := make(map[int64]string)
+	for _, typeValue := range graph.Cha {
+		for id, method := range typeValue.Methods {
+			methods[id] = method
+			if function, ok := typeValue.MethodMetadata[id]["definedIn"].(string); ok {
+				definedIn[id] = function
+			}
+		}
+	}
+	ids := make([]int64, 0, len(methods))
+	for id := range methods {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		uri := methods[id]
+		if parsed, err := fasten.ParseURI(uri); err == nil {
+			parsed.Forge, parsed.Product, parsed.Version = graph.Forge, graph.Product, graph.Version
+			uri = parsed.String()
+		}
+		if _, exists := stitched.nodes[uri]; !exists {
+			stitched.nodes[uri] = int64(len(stitched.Nodes))
+			stitched.Nodes[stitched.nodes[uri]] = uri
+		}
+		added.ids[id] = stitched.nodes[uri]
+	}
+
+	for _, id := range ids {
+		if function, exists := graph.DuplicateCHA[definedIn[id]]; exists && definedIn[id] != "" {
+			stitched.DefinedIn = append(stitched.DefinedIn, []int64{added.ids[id], added.ids[function]})
+		}
+	}
+	return added
+}
+
+// Reports a dependency of the package whose graph could not be stitched.
+func (stitched *Graph) addMissing(dependent string, dependency fasten.Dependency, reason string, message string) {
+	stitched.MissingDependencies = append(stitched.MissingDependencies,
+		MissingDependency{Dependent: dependent, Dependency: dependency, Reason: reason, Message: message})
+}
+
+// Reports an external call which could not be resolved.
+func (stitched *Graph) addUnresolved(source int64, target string, reason string, metadata map[string]interface{}) {
+	stitched.UnresolvedCalls = append(stitched.UnresolvedCalls,
+		UnresolvedCall{Source: source, Target: target, Reason: reason, Metadata: metadata})
+}
+
+// Returns the key of a package, its crate name and version.
+func packageKey(product string, version string) string {
+	return cargo.CrateName(product) + "$" + version
+}
@@ -0,0 +1,122 @@
+package stitch
+
+import (
+	"RustCallGraphConverter/src/internal/cargo"
+	"RustCallGraphConverter/src/internal/fasten"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Error returned for packages missing in the store.
+var ErrNotFound = errors.New("package not found in store")
+
+// Converted call graphs found in a directory, e.g. the output directory of the converter
+// containing fasten/<package>/<product>-<version>.json. Graphs are identified by the
+// product and version in their file name and loaded on first use.
+type Store struct {
+	files  map[string]map[string]string
+	graphs map[string]*fasten.JSON
+}
+
+// Creates an empty store.
+func NewStore() *Store {
+	return &Store{
+		files:  make(map[string]map[string]string),
+		graphs: make(map[string]*fasten.JSON),
+	}
+}
+
+// Indexes all call graphs in the directory and its subdirectories.
+func OpenStore(directory string) (*Store, error) {
+	store := NewStore()
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".json") {
+			store.add(path)
+		}
+		return nil
+	})
+	return store, err
+}
+
+// Adds the file to the index under every split of its name into a product
+// and a semantic version.
+func (store *Store) add(path string) {
+	name := strings.TrimSuffix(filepath.Base(path), ".json")
+	for i := strings.Index(name, "-"); i >= 0; {
+		product, version := cargo.CrateName(name[:i]), name[i+1:]
+		if _, err := cargo.ParseVersion(version); err == nil {
+			if _, exists := store.files[product]; !exists {
+				store.files[product] = make(map[string]string)
+			}
+			store.files[product][version] = path
+		}
+		next := strings.Index(name[i+1:], "-")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+}
+
+// Returns the versions of the product in the store in ascending order.
+func (store *Store) Versions(product string) []string {
+	versions := make([]string, 0, len(store.files[cargo.CrateName(product)]))
+	for version := range store.files[cargo.CrateName(product)] {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		first, _ := cargo.ParseVersion(versions[i])
+		second, _ := cargo.ParseVersion(versions[j])
+		return first.Compare(second) < 0
+	})
+	return versions
+}
+
+// Loads the call graph of the product in the version. Returns ErrNotFound if the store
+// does not contain it.
+func (store *Store) Load(product string, version string) (*fasten.JSON, error) {
+	product = cargo.CrateName(product)
+	key := product + "$" + version
+	if graph, exists := store.graphs[key]; exists {
+		return graph, nil
+	}
+	path, exists := store.files[product][version]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	graph, err := fasten.Load(file)
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	store.graphs[key] = graph
+	return graph, nil
+}
+
+// Returns the version of the dependency to stitch: the version resolved from Cargo.lock,
+// or else the highest version in the store satisfying one of the constraints.
+func (store *Store) Resolve(dependency fasten.Dependency) (string, bool) {
+	if dependency.Resolved != "" {
+		return dependency.Resolved, true
+	}
+	versions := store.Versions(dependency.Product)
+	for i := len(versions) - 1; i >= 0; i-- {
+		version, _ := cargo.ParseVersion(versions[i])
+		for _, constraint := range dependency.Constraints {
+			if versionRange, err := cargo.ParseConstraint(constraint); err == nil && versionRange.Contains(version) {
+				return versions[i], true
+			}
+		}
+	}
+	return "", false
+}